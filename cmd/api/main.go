@@ -68,6 +68,10 @@ func setupRouter(ctrl *controllers.Controller) *gin.Engine {
 
 	api := r.Group("/api/v1")
 	api.GET("/trades", ctrl.GetTrade)
+	api.POST("/trades/aggregate", ctrl.AggregateTrades)
+	api.GET("/tickers/:ticker/daily", ctrl.GetDailyStats)
+	api.GET("/tickers/:ticker/candles", ctrl.GetCandles)
+	api.GET("/tickers/:ticker/averages", ctrl.GetAveragePrices)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	return r
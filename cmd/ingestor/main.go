@@ -31,7 +31,17 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
 	defer cancel()
 
-	pool, err := pgxpool.New(ctx, cfg.DatabaseURL)
+	poolConfig, err := pgxpool.ParseConfig(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Each worker holds a connection for its file transaction, plus one for the ledger.
+	if minConns := int32(cfg.Workers + 1); poolConfig.MaxConns < minConns {
+		poolConfig.MaxConns = minConns
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -41,15 +51,35 @@ func main() {
 		log.Fatal(err)
 	}
 
-	csvReader := reader.NewCSVReader(cfg.FilePath, ';', -1, l)
+	errorPolicy, err := trade.ParseErrorPolicy(cfg.ErrorPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	csvReader := reader.NewCSVReader(cfg.FilePath, ';', -1, cfg.ChunkSize, l)
 	repository := storage.NewTradeRepository(pool)
-	service := trade.NewService(repository, csvReader, l)
+	service := trade.NewService(repository, csvReader, l,
+		trade.WithErrorPolicy(errorPolicy, cfg.MaxRejected),
+		trade.WithWorkers(cfg.Workers),
+	)
 
 	l.Info("data ingestion started")
-	err = service.IngestFiles(ctx, cfg.FilePath)
+	summary, err := service.IngestFiles(ctx, cfg.FilePath)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	l.Info("data ingestion finished",
+		zap.Int("files", summary.Files),
+		zap.Int("skipped", summary.Skipped),
+		zap.Int64("rows", summary.Rows),
+		zap.Int64("inserted", summary.Inserted),
+		zap.Int64("duplicated", summary.Duplicated),
+		zap.Int64("corrected", summary.Corrected),
+		zap.Int64("cancelled", summary.Cancelled),
+		zap.Int64("rejected", summary.Rejected),
+		zap.Int("missing_sessions", len(summary.MissingSessions)),
+	)
 }
 
 func migrations(database string) {
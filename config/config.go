@@ -11,6 +11,10 @@ type Config struct {
 	DatabaseURL string `mapstructure:"DATABASE_URL"`
 	LogLevel    string `mapstructure:"LOG_LEVEL"`
 	ServerPort  string `mapstructure:"SERVER_PORT"`
+	ChunkSize   int    `mapstructure:"CHUNK_SIZE"`
+	ErrorPolicy string `mapstructure:"ERROR_POLICY"`
+	MaxRejected int    `mapstructure:"MAX_REJECTED_ROWS"`
+	Workers     int    `mapstructure:"INGEST_WORKERS"`
 }
 
 func LoadEnvs() (*Config, error) {
@@ -28,6 +32,10 @@ func LoadEnvs() (*Config, error) {
 	viper.SetDefault("LOG_LEVEL", "info")
 	viper.SetDefault("FILE_PATH", "")
 	viper.SetDefault("SERVER_PORT", "8083")
+	viper.SetDefault("CHUNK_SIZE", 50000)
+	viper.SetDefault("ERROR_POLICY", "fail-fast")
+	viper.SetDefault("MAX_REJECTED_ROWS", 1000)
+	viper.SetDefault("INGEST_WORKERS", 4)
 
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
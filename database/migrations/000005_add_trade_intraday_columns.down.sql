@@ -0,0 +1,11 @@
+BEGIN;
+
+ALTER TABLE trades
+    DROP COLUMN IF EXISTS codigo_participante_vendedor,
+    DROP COLUMN IF EXISTS codigo_participante_comprador,
+    DROP COLUMN IF EXISTS tipo_sessao_pregao,
+    DROP COLUMN IF EXISTS codigo_identificador_negocio,
+    DROP COLUMN IF EXISTS acao_atualizacao,
+    DROP COLUMN IF EXISTS data_referencia;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+ALTER TABLE trades
+    ADD COLUMN data_referencia DATE,
+    ADD COLUMN acao_atualizacao SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN codigo_identificador_negocio BIGINT,
+    ADD COLUMN tipo_sessao_pregao SMALLINT,
+    ADD COLUMN codigo_participante_comprador INT,
+    ADD COLUMN codigo_participante_vendedor INT;
+
+COMMIT;
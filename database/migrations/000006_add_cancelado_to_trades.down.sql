@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_trades_negocio;
+
+ALTER TABLE trades DROP COLUMN IF EXISTS cancelado;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE trades ADD COLUMN cancelado BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_trades_negocio
+    ON trades (data_negocio, codigo_instrumento, codigo_identificador_negocio);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE trades DROP CONSTRAINT IF EXISTS uq_trades_negocio;
+
+CREATE INDEX idx_trades_negocio
+    ON trades (data_negocio, codigo_instrumento, codigo_identificador_negocio);
+
+COMMIT;
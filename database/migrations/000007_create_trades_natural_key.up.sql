@@ -0,0 +1,16 @@
+BEGIN;
+
+DELETE FROM trades a
+    USING trades b
+WHERE a.id > b.id
+    AND a.data_negocio = b.data_negocio
+    AND a.codigo_instrumento = b.codigo_instrumento
+    AND a.codigo_identificador_negocio = b.codigo_identificador_negocio;
+
+DROP INDEX IF EXISTS idx_trades_negocio;
+
+ALTER TABLE trades
+    ADD CONSTRAINT uq_trades_negocio
+    UNIQUE (data_negocio, codigo_instrumento, codigo_identificador_negocio);
+
+COMMIT;
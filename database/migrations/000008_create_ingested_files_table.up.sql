@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE ingested_files (
+    id BIGSERIAL PRIMARY KEY,
+    file_name TEXT NOT NULL UNIQUE,
+    file_size BIGINT NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    rows_read BIGINT NOT NULL DEFAULT 0,
+    rows_inserted BIGINT NOT NULL DEFAULT 0,
+    rows_duplicated BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP
+);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE ingested_files DROP COLUMN IF EXISTS rows_rejected;
+
+DROP TABLE IF EXISTS rejected_trades;
+
+COMMIT;
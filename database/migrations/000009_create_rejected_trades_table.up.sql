@@ -0,0 +1,16 @@
+BEGIN;
+
+CREATE TABLE rejected_trades (
+    id BIGSERIAL PRIMARY KEY,
+    file_name TEXT NOT NULL,
+    line_number INT NOT NULL,
+    raw_line TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_rejected_trades_file ON rejected_trades (file_name, line_number);
+
+ALTER TABLE ingested_files ADD COLUMN rows_rejected BIGINT NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE instruments (
+    data_referencia DATE NOT NULL,
+    codigo_instrumento VARCHAR(50) NOT NULL,
+    ativo VARCHAR(50) NOT NULL,
+    segmento TEXT NOT NULL,
+    mercado TEXT NOT NULL,
+    categoria TEXT NOT NULL,
+    isin VARCHAR(12) NOT NULL,
+    data_vencimento DATE,
+    razao_social TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (data_referencia, codigo_instrumento)
+);
+
+CREATE INDEX idx_instruments_codigo ON instruments (codigo_instrumento, data_referencia DESC);
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+CREATE TABLE daily_bars (
+    data_pregao DATE NOT NULL,
+    codigo_bdi VARCHAR(2) NOT NULL,
+    codigo_instrumento VARCHAR(50) NOT NULL,
+    tipo_mercado SMALLINT NOT NULL,
+    preco_abertura NUMERIC(13,2) NOT NULL,
+    preco_maximo NUMERIC(13,2) NOT NULL,
+    preco_minimo NUMERIC(13,2) NOT NULL,
+    preco_medio NUMERIC(13,2) NOT NULL,
+    preco_fechamento NUMERIC(13,2) NOT NULL,
+    numero_negocios INT NOT NULL,
+    quantidade_total BIGINT NOT NULL,
+    volume_total NUMERIC(18,2) NOT NULL,
+    fator_cotacao INT NOT NULL,
+    isin VARCHAR(12) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (data_pregao, codigo_instrumento, tipo_mercado)
+);
+
+CREATE INDEX idx_daily_bars_instrumento_data ON daily_bars (codigo_instrumento, data_pregao);
+
+COMMIT;
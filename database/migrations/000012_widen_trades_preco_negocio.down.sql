@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE trades ALTER COLUMN preco_negocio TYPE NUMERIC(10, 2);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Options, futures (WIN, DOL, DI1) and FII quotes are published with more
+-- decimal places or larger magnitudes than NUMERIC(10, 2) holds.
+ALTER TABLE trades ALTER COLUMN preco_negocio TYPE NUMERIC(20, 8);
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_trades_instrumento_data_hora;
+
+ALTER TABLE trades ADD COLUMN hora_fechamento TIME;
+
+UPDATE trades
+SET hora_fechamento = date_trunc('second', data_hora_negocio AT TIME ZONE 'America/Sao_Paulo')::time;
+
+ALTER TABLE trades
+    ALTER COLUMN hora_fechamento SET NOT NULL,
+    DROP COLUMN data_hora_negocio;
+
+COMMIT;
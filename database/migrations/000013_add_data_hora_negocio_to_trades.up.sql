@@ -0,0 +1,15 @@
+BEGIN;
+
+ALTER TABLE trades ADD COLUMN data_hora_negocio TIMESTAMPTZ;
+
+-- hora_fechamento was stored without milliseconds, in São Paulo time.
+UPDATE trades
+SET data_hora_negocio = (data_negocio + hora_fechamento) AT TIME ZONE 'America/Sao_Paulo';
+
+ALTER TABLE trades
+    ALTER COLUMN data_hora_negocio SET NOT NULL,
+    DROP COLUMN hora_fechamento;
+
+CREATE INDEX idx_trades_instrumento_data_hora ON trades (codigo_instrumento, data_hora_negocio);
+
+COMMIT;
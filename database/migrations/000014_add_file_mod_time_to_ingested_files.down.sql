@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_ingested_files_checksum;
+
+ALTER TABLE ingested_files DROP COLUMN IF EXISTS file_mod_time;
+
+COMMIT;
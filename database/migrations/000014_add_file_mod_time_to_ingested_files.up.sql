@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE ingested_files ADD COLUMN file_mod_time TIMESTAMPTZ;
+
+CREATE INDEX idx_ingested_files_checksum ON ingested_files (checksum);
+
+COMMIT;
@@ -15,9 +15,243 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/tickers/{ticker}/averages": {
+            "get": {
+                "description": "Retorna, para cada pregão do período com negócios, o preço médio ponderado pelo volume (VWAP) e pelo tempo (TWAP) do ticker e, quando informado um intervalo, os mesmos valores por janela intradiária",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "trade"
+                ],
+                "summary": "Obtém VWAP e TWAP de um ticker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Código do ticker (ex: PETR4), sem diferenciar maiúsculas",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Data de início no formato YYYY-MM-DD (padrão: 7 pregões até data_fim)",
+                        "name": "data_inicio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Data de fim, inclusiva, no formato YYYY-MM-DD (padrão: último pregão até hoje)",
+                        "name": "data_fim",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "1m",
+                            "5m",
+                            "15m",
+                            "1h"
+                        ],
+                        "type": "string",
+                        "description": "Intervalo das janelas intradiárias (padrão: sem janelas)",
+                        "name": "interval",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/trade.AveragePrices"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                }
+            }
+        },
+        "/tickers/{ticker}/candles": {
+            "get": {
+                "description": "Retorna os candles (abertura, máxima, mínima, fechamento, volume e quantidade de negócios) de um ticker em um pregão, por intervalo. Com format=columnar, a resposta é um trade.CandleColumns, com um array por campo",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "trade"
+                ],
+                "summary": "Obtém candles intradiários de um ticker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Código do ticker (ex: PETR4), sem diferenciar maiúsculas",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Data do pregão no formato YYYY-MM-DD (padrão: último pregão até hoje)",
+                        "name": "data",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "1m",
+                            "5m",
+                            "15m",
+                            "1h"
+                        ],
+                        "type": "string",
+                        "default": "5m",
+                        "description": "Intervalo dos candles",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "columnar"
+                        ],
+                        "type": "string",
+                        "default": "json",
+                        "description": "Formato da resposta",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/trade.CandleSeries"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                }
+            }
+        },
+        "/tickers/{ticker}/daily": {
+            "get": {
+                "description": "Retorna, para cada pregão do período com negócios, abertura, máxima, mínima, fechamento, VWAP, volume e quantidade de negócios do ticker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "trade"
+                ],
+                "summary": "Obtém a série diária de um ticker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Código do ticker (ex: PETR4), sem diferenciar maiúsculas",
+                        "name": "ticker",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Data de início no formato YYYY-MM-DD (padrão: 7 pregões até data_fim)",
+                        "name": "data_inicio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Data de fim, inclusiva, no formato YYYY-MM-DD (padrão: último pregão até hoje)",
+                        "name": "data_fim",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/trade.DailySeries"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                }
+            }
+        },
         "/trades": {
             "get": {
-                "description": "Retorna dados agregados de um ticker específico, podendo filtrar por data de início",
+                "description": "Retorna dados agregados de um ticker específico, podendo filtrar por data de início e de fim",
                 "consumes": [
                     "application/json"
                 ],
@@ -31,16 +265,28 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Código do ticker (ex: PETR4)",
+                        "description": "Código do ticker (ex: PETR4), sem diferenciar maiúsculas",
                         "name": "ticker",
                         "in": "query",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Data de início no formato YYYY-MM-DD",
+                        "description": "Data de início no formato YYYY-MM-DD (padrão: 7 pregões até data_fim)",
                         "name": "data_inicio",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Data de fim, inclusiva, no formato YYYY-MM-DD (padrão: último pregão até hoje)",
+                        "name": "data_fim",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Agrega também os negócios do lote padrão de um ticker do mercado fracionário (ex: PETR4 para PETR4F)",
+                        "name": "incluir_lote_padrao",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -56,30 +302,387 @@ const docTemplate = `{
                             "type": "object"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "type": "object"
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                }
+            }
+        },
+        "/trades/aggregate": {
+            "post": {
+                "description": "Retorna os dados agregados de uma lista de tickers no mesmo período, em uma única consulta. Tickers sem negócios no período são listados em not_found",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "trade"
+                ],
+                "summary": "Obtém dados agregados de vários tickers",
+                "parameters": [
+                    {
+                        "description": "Tickers (até 200) e período, com datas no formato YYYY-MM-DD e os mesmos padrões de GET /trades",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.AggregateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/trade.AggregatedBatch"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
+        "controllers.AggregateRequest": {
+            "type": "object",
+            "properties": {
+                "data_fim": {
+                    "type": "string",
+                    "example": "2025-08-06"
+                },
+                "data_inicio": {
+                    "type": "string",
+                    "example": "2025-07-29"
+                },
+                "tickers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "PETR4",
+                        "VALE3",
+                        "ITUB4"
+                    ]
+                }
+            }
+        },
+        "trade.AggregatedBatch": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data has one entry per ticker with trades in the period, in the order requested.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/trade.AggregatedData"
+                    }
+                },
+                "end_date": {
+                    "type": "string",
+                    "example": "2025-08-06T00:00:00Z"
+                },
+                "not_found": {
+                    "description": "NotFound lists the tickers without trades in the period.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "XPTO3"
+                    ]
+                },
+                "start_date": {
+                    "type": "string",
+                    "example": "2025-07-29T00:00:00Z"
+                }
+            }
+        },
         "trade.AggregatedData": {
             "type": "object",
             "properties": {
+                "close_price": {
+                    "type": "string",
+                    "example": "36.90"
+                },
+                "end_date": {
+                    "type": "string",
+                    "example": "2025-08-06T00:00:00Z"
+                },
                 "max_daily_volume": {
+                    "description": "MaxDailyVolume is the largest quantity traded in a single day of the\nperiod, and MaxDailyVolumeDate the day it happened (the earliest on ties).",
                     "type": "integer"
                 },
+                "max_daily_volume_date": {
+                    "type": "string",
+                    "example": "2025-08-06T00:00:00Z"
+                },
                 "max_range_value": {
-                    "type": "number"
+                    "type": "string",
+                    "example": "37.05"
+                },
+                "min_range_value": {
+                    "type": "string",
+                    "example": "35.80"
+                },
+                "open_price": {
+                    "description": "OpenPrice and ClosePrice are the prices of the first and last trades of the period.",
+                    "type": "string",
+                    "example": "36.12"
+                },
+                "start_date": {
+                    "description": "StartDate and EndDate are the first and last days of the period, inclusive.",
+                    "type": "string",
+                    "example": "2025-07-29T00:00:00Z"
+                },
+                "ticker": {
+                    "type": "string"
+                },
+                "total_volume": {
+                    "description": "TotalVolume is the quantity traded in the whole period.",
+                    "type": "integer",
+                    "example": 48210300
+                },
+                "trade_count": {
+                    "type": "integer",
+                    "example": 152340
+                }
+            }
+        },
+        "trade.AveragePrices": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "type": "string",
+                    "example": "2025-08-06T00:00:00Z"
+                },
+                "interval": {
+                    "description": "Interval is the length of the windows, empty when they were not requested.",
+                    "type": "string",
+                    "example": "15m"
+                },
+                "sessions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/trade.SessionAverages"
+                    }
+                },
+                "start_date": {
+                    "type": "string",
+                    "example": "2025-07-29T00:00:00Z"
+                },
+                "ticker": {
+                    "type": "string"
+                }
+            }
+        },
+        "trade.Candle": {
+            "type": "object",
+            "properties": {
+                "close": {
+                    "type": "string",
+                    "example": "36.18"
+                },
+                "high": {
+                    "type": "string",
+                    "example": "36.20"
+                },
+                "low": {
+                    "type": "string",
+                    "example": "36.05"
+                },
+                "open": {
+                    "description": "Open and Close are the prices of the first and last trades of the interval.",
+                    "type": "string",
+                    "example": "36.12"
+                },
+                "time": {
+                    "description": "Time is the start of the interval, in São Paulo time. Intervals are\naligned to the start of the day.",
+                    "type": "string",
+                    "example": "2025-08-06T10:05:00-03:00"
+                },
+                "trade_count": {
+                    "type": "integer",
+                    "example": 612
+                },
+                "volume": {
+                    "type": "integer",
+                    "example": 184300
+                }
+            }
+        },
+        "trade.CandleSeries": {
+            "type": "object",
+            "properties": {
+                "candles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/trade.Candle"
+                    }
+                },
+                "date": {
+                    "type": "string",
+                    "example": "2025-08-06T00:00:00Z"
+                },
+                "interval": {
+                    "type": "string",
+                    "example": "5m"
                 },
                 "ticker": {
                     "type": "string"
                 }
             }
+        },
+        "trade.DailySeries": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "type": "string",
+                    "example": "2025-08-06T00:00:00Z"
+                },
+                "sessions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/trade.DailyStats"
+                    }
+                },
+                "start_date": {
+                    "type": "string",
+                    "example": "2025-07-29T00:00:00Z"
+                },
+                "ticker": {
+                    "type": "string"
+                }
+            }
+        },
+        "trade.DailyStats": {
+            "type": "object",
+            "properties": {
+                "close": {
+                    "type": "string",
+                    "example": "36.90"
+                },
+                "date": {
+                    "type": "string",
+                    "example": "2025-08-06T00:00:00Z"
+                },
+                "high": {
+                    "type": "string",
+                    "example": "37.05"
+                },
+                "low": {
+                    "type": "string",
+                    "example": "35.80"
+                },
+                "open": {
+                    "description": "Open and Close are the prices of the first and last trades of the session.",
+                    "type": "string",
+                    "example": "36.12"
+                },
+                "trade_count": {
+                    "type": "integer",
+                    "example": 30512
+                },
+                "volume": {
+                    "type": "integer",
+                    "example": 9840100
+                },
+                "vwap": {
+                    "description": "VWAP is the volume-weighted average price, rounded to 8 decimal places.",
+                    "type": "string",
+                    "example": "36.47215530"
+                }
+            }
+        },
+        "trade.SessionAverages": {
+            "type": "object",
+            "properties": {
+                "date": {
+                    "type": "string",
+                    "example": "2025-08-06T00:00:00Z"
+                },
+                "trade_count": {
+                    "type": "integer",
+                    "example": 30512
+                },
+                "twap": {
+                    "type": "string",
+                    "example": "36.45102311"
+                },
+                "volume": {
+                    "type": "integer",
+                    "example": 9840100
+                },
+                "vwap": {
+                    "type": "string",
+                    "example": "36.47215530"
+                },
+                "windows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/trade.WindowAverages"
+                    }
+                }
+            }
+        },
+        "trade.WindowAverages": {
+            "type": "object",
+            "properties": {
+                "time": {
+                    "description": "Time is the start of the window, in São Paulo time. Windows are aligned\nto the start of the day.",
+                    "type": "string",
+                    "example": "2025-08-06T10:05:00-03:00"
+                },
+                "trade_count": {
+                    "type": "integer",
+                    "example": 30512
+                },
+                "twap": {
+                    "type": "string",
+                    "example": "36.45102311"
+                },
+                "volume": {
+                    "type": "integer",
+                    "example": 9840100
+                },
+                "vwap": {
+                    "type": "string",
+                    "example": "36.47215530"
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,173 @@
+// Package calendar implements the trading calendar of B3: which days have a
+// trading session (pregão) and how to move between sessions.
+//
+// Days are calendar dates. Functions accept any time.Time and only look at its
+// year, month and day; dates they return are at midnight UTC, like dates parsed
+// with time.Parse("2006-01-02", ...).
+package calendar
+
+import (
+	"time"
+	_ "time/tzdata"
+)
+
+// SaoPaulo is the time zone of B3, in which sessions open and close and trade
+// times are published. It is loaded from the database embedded by time/tzdata,
+// so it does not depend on the zoneinfo files of the host.
+var SaoPaulo = mustLoadLocation("America/Sao_Paulo")
+
+var newYork = mustLoadLocation("America/New_York")
+
+// holiday is a day without trading that falls on the same date every year,
+// observed by B3 from the year since up to the year until. Zero means no bound.
+type holiday struct {
+	month time.Month
+	day   int
+	name  string
+	since int
+	until int
+}
+
+// fixedHolidays are the national holidays, plus the São Paulo holidays B3 kept
+// until 2021 and the days around the new year it does not open.
+var fixedHolidays = []holiday{
+	{month: time.January, day: 1, name: "Confraternização Universal"},
+	{month: time.January, day: 25, name: "Aniversário de São Paulo", until: 2021},
+	{month: time.April, day: 21, name: "Tiradentes"},
+	{month: time.May, day: 1, name: "Dia do Trabalho"},
+	{month: time.July, day: 9, name: "Revolução Constitucionalista", until: 2021},
+	{month: time.September, day: 7, name: "Independência do Brasil"},
+	{month: time.October, day: 12, name: "Nossa Senhora Aparecida"},
+	{month: time.November, day: 2, name: "Finados"},
+	{month: time.November, day: 15, name: "Proclamação da República"},
+	// A São Paulo holiday until 2021 and a national one since 2024.
+	{month: time.November, day: 20, name: "Dia da Consciência Negra", until: 2021},
+	{month: time.November, day: 20, name: "Dia da Consciência Negra", since: 2024},
+	{month: time.December, day: 24, name: "Véspera de Natal"},
+	{month: time.December, day: 25, name: "Natal"},
+	{month: time.December, day: 31, name: "Último dia do ano"},
+}
+
+// easterHolidays are the holidays that move with Easter, as days from Easter Sunday.
+var easterHolidays = []struct {
+	offset int
+	name   string
+}{
+	{offset: -48, name: "Carnaval"},
+	{offset: -47, name: "Carnaval"},
+	{offset: -2, name: "Sexta-feira Santa"},
+	{offset: 60, name: "Corpus Christi"},
+}
+
+// Holiday reports whether B3 is closed for a holiday on day, and its name.
+// Weekends are not holidays.
+func Holiday(day time.Time) (string, bool) {
+	day = date(day)
+	year := day.Year()
+
+	for _, h := range fixedHolidays {
+		if h.month != day.Month() || h.day != day.Day() {
+			continue
+		}
+		if (h.since == 0 || year >= h.since) && (h.until == 0 || year <= h.until) {
+			return h.name, true
+		}
+	}
+
+	easter := Easter(year)
+	for _, h := range easterHolidays {
+		if easter.AddDate(0, 0, h.offset).Equal(day) {
+			return h.name, true
+		}
+	}
+
+	return "", false
+}
+
+// IsSession reports whether B3 has a trading session on day.
+func IsSession(day time.Time) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	_, closed := Holiday(day)
+	return !closed
+}
+
+// LastSession returns day itself when it has a session, or the session before it.
+func LastSession(day time.Time) time.Time {
+	day = date(day)
+	for !IsSession(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// AddSessions moves n sessions from day, backwards when n is negative. Day
+// itself does not need to have a session.
+func AddSessions(day time.Time, n int) time.Time {
+	day = date(day)
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for n > 0 {
+		day = day.AddDate(0, 0, step)
+		if IsSession(day) {
+			n--
+		}
+	}
+	return day
+}
+
+// Sessions lists the sessions from start to end, inclusive.
+func Sessions(start, end time.Time) []time.Time {
+	var sessions []time.Time
+	for day := date(start); !day.After(date(end)); day = day.AddDate(0, 0, 1) {
+		if IsSession(day) {
+			sessions = append(sessions, day)
+		}
+	}
+	return sessions
+}
+
+// SessionClose returns the end of the regular session on day. B3 moves its
+// hours with the daylight saving time of the US market: the session closes at
+// 18:00 in São Paulo, or at 17:00 while New York observes daylight saving time.
+func SessionClose(day time.Time) time.Time {
+	day = date(day)
+	hour := 18
+	if time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, newYork).IsDST() {
+		hour = 17
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, SaoPaulo)
+}
+
+// Easter returns Easter Sunday of year, by the anonymous Gregorian algorithm.
+func Easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// mustLoadLocation loads a time zone, panicking if it is unknown.
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
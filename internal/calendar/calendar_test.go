@@ -0,0 +1,121 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func day(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestEaster(t *testing.T) {
+	assert.Equal(t, day("2023-04-09"), Easter(2023))
+	assert.Equal(t, day("2024-03-31"), Easter(2024))
+	assert.Equal(t, day("2025-04-20"), Easter(2025))
+	assert.Equal(t, day("2026-04-05"), Easter(2026))
+}
+
+func TestHoliday(t *testing.T) {
+	tests := []struct {
+		day  string
+		name string
+	}{
+		{"2025-01-01", "Confraternização Universal"},
+		{"2025-03-03", "Carnaval"},
+		{"2025-03-04", "Carnaval"},
+		{"2025-04-18", "Sexta-feira Santa"},
+		{"2025-04-21", "Tiradentes"},
+		{"2025-06-19", "Corpus Christi"},
+		{"2024-11-20", "Dia da Consciência Negra"},
+		{"2021-11-20", "Dia da Consciência Negra"},
+		{"2021-01-25", "Aniversário de São Paulo"},
+		{"2024-12-24", "Véspera de Natal"},
+		{"2024-12-31", "Último dia do ano"},
+		// B3 opens on São Paulo holidays since 2022.
+		{"2023-11-20", ""},
+		{"2022-01-25", ""},
+		{"2024-07-09", ""},
+		// Ash Wednesday has a late session.
+		{"2025-03-05", ""},
+		{"2025-08-06", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.day, func(t *testing.T) {
+			name, ok := Holiday(day(tt.day))
+			assert.Equal(t, tt.name != "", ok)
+			assert.Equal(t, tt.name, name)
+		})
+	}
+}
+
+func TestIsSession(t *testing.T) {
+	assert.True(t, IsSession(day("2025-08-06")))
+	assert.False(t, IsSession(day("2025-08-09")), "saturday")
+	assert.False(t, IsSession(day("2025-08-10")), "sunday")
+	assert.False(t, IsSession(day("2025-03-04")), "carnaval")
+	// Only the date matters, not the time of day or location.
+	saoPaulo := time.FixedZone("BRT", -3*60*60)
+	assert.True(t, IsSession(time.Date(2025, 8, 6, 23, 30, 0, 0, saoPaulo)))
+}
+
+func TestLastSession(t *testing.T) {
+	assert.Equal(t, day("2025-08-06"), LastSession(day("2025-08-06")))
+	assert.Equal(t, day("2025-08-08"), LastSession(day("2025-08-10")))
+	// Carnaval Monday and Tuesday follow a weekend.
+	assert.Equal(t, day("2025-02-28"), LastSession(day("2025-03-04")))
+}
+
+func TestAddSessions(t *testing.T) {
+	assert.Equal(t, day("2025-08-06"), AddSessions(day("2025-08-06"), 0))
+	assert.Equal(t, day("2025-08-11"), AddSessions(day("2025-08-08"), 1))
+	assert.Equal(t, day("2025-03-05"), AddSessions(day("2025-02-28"), 1))
+	assert.Equal(t, day("2025-02-26"), AddSessions(day("2025-03-05"), -3))
+	assert.Equal(t, day("2025-03-07"), AddSessions(day("2025-03-02"), 3))
+}
+
+func TestSessions(t *testing.T) {
+	sessions := Sessions(day("2025-02-27"), day("2025-03-06"))
+
+	assert.Equal(t, []time.Time{
+		day("2025-02-27"),
+		day("2025-02-28"),
+		day("2025-03-05"),
+		day("2025-03-06"),
+	}, sessions)
+	assert.Empty(t, Sessions(day("2025-03-06"), day("2025-03-05")))
+}
+
+func TestSessionClose(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	tests := []struct {
+		day  string
+		hour int
+	}{
+		{"2025-01-15", 18},
+		// New York moves to daylight saving time on the second Sunday of March.
+		{"2025-03-07", 18},
+		{"2025-03-10", 17},
+		{"2025-08-06", 17},
+		// And back to standard time on the first Sunday of November.
+		{"2025-10-31", 17},
+		{"2025-11-03", 18},
+	}
+	for _, tt := range tests {
+		d := day(tt.day)
+		want := time.Date(d.Year(), d.Month(), d.Day(), tt.hour, 0, 0, 0, saoPaulo)
+		got := SessionClose(d)
+		assert.True(t, want.Equal(got), "%s: expected %s, got %s", tt.day, want, got)
+	}
+}
@@ -1,7 +1,11 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -23,42 +27,285 @@ func NewController(s trade.Usecase, l *zap.Logger) *Controller {
 
 // GetTrade godoc
 // @Summary      Obtém dados agregados de negociações
-// @Description  Retorna dados agregados de um ticker específico, podendo filtrar por data de início
+// @Description  Retorna dados agregados de um ticker específico, podendo filtrar por data de início e de fim
 // @Tags         trade
 // @Accept       json
 // @Produce      json
-// @Param        ticker       query     string  true  "Código do ticker (ex: PETR4)"
-// @Param        data_inicio  query     string  false "Data de início no formato YYYY-MM-DD"
-// @Success      200          {object}  trade.AggregatedData
-// @Failure      400          {object}  object
-// @Failure      500          {object}  object
+// @Param        ticker               query     string  true  "Código do ticker (ex: PETR4), sem diferenciar maiúsculas"
+// @Param        data_inicio          query     string  false "Data de início no formato YYYY-MM-DD (padrão: 7 pregões até data_fim)"
+// @Param        data_fim             query     string  false "Data de fim, inclusiva, no formato YYYY-MM-DD (padrão: último pregão até hoje)"
+// @Param        incluir_lote_padrao  query     bool    false "Agrega também os negócios do lote padrão de um ticker do mercado fracionário (ex: PETR4 para PETR4F)"
+// @Success      200                  {object}  trade.AggregatedData
+// @Failure      400                  {object}  object
+// @Failure      404                  {object}  object
+// @Failure      500                  {object}  object
+// @Failure      503                  {object}  object
 // @Router       /trades [get]
 func (ctrl *Controller) GetTrade(ctx *gin.Context) {
-	ticker := ctx.Query("ticker")
-	if ticker == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "ticker is required"})
+	ticker, err := trade.ParseTicker(ctx.Query("ticker"))
+	if err != nil {
+		ctrl.respondError(ctx, err)
 		return
 	}
-	ctrl.logger.Info("ticker recognized", zap.String("ticker", ticker))
+	ctrl.logger.Info("ticker recognized", zap.String("ticker", ticker.Code))
 
-	dataInicio := ctx.Query("data_inicio")
-	var startDate *time.Time
+	startDate, err := parseDateQuery(ctx, "data_inicio")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if dataInicio != "" {
-		parsedDate, err := time.Parse("2006-01-02", dataInicio)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid data_inicio format"})
-			return
-		}
-		startDate = &parsedDate
+	endDate, err := parseDateQuery(ctx, "data_fim")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	includeLot, err := parseBoolQuery(ctx, "incluir_lote_padrao")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	ctrl.logger.Info("getting aggregated data")
-	result, err := ctrl.service.GetAggregatedData(ctx.Request.Context(), ticker, startDate)
+	result, err := ctrl.service.GetAggregatedData(ctx.Request.Context(), ticker.Code, startDate, endDate, includeLot)
+	if err != nil {
+		ctrl.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// GetDailyStats godoc
+// @Summary      Obtém a série diária de um ticker
+// @Description  Retorna, para cada pregão do período com negócios, abertura, máxima, mínima, fechamento, VWAP, volume e quantidade de negócios do ticker
+// @Tags         trade
+// @Accept       json
+// @Produce      json
+// @Param        ticker       path      string  true  "Código do ticker (ex: PETR4), sem diferenciar maiúsculas"
+// @Param        data_inicio  query     string  false "Data de início no formato YYYY-MM-DD (padrão: 7 pregões até data_fim)"
+// @Param        data_fim     query     string  false "Data de fim, inclusiva, no formato YYYY-MM-DD (padrão: último pregão até hoje)"
+// @Success      200          {object}  trade.DailySeries
+// @Failure      400          {object}  object
+// @Failure      404          {object}  object
+// @Failure      500          {object}  object
+// @Failure      503          {object}  object
+// @Router       /tickers/{ticker}/daily [get]
+func (ctrl *Controller) GetDailyStats(ctx *gin.Context) {
+	startDate, err := parseDateQuery(ctx, "data_inicio")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	endDate, err := parseDateQuery(ctx, "data_fim")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ticker := ctx.Param("ticker")
+	ctrl.logger.Info("getting daily stats", zap.String("ticker", ticker))
+	result, err := ctrl.service.GetDailyStats(ctx.Request.Context(), ticker, startDate, endDate)
+	if err != nil {
+		ctrl.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// GetAveragePrices godoc
+// @Summary      Obtém VWAP e TWAP de um ticker
+// @Description  Retorna, para cada pregão do período com negócios, o preço médio ponderado pelo volume (VWAP) e pelo tempo (TWAP) do ticker e, quando informado um intervalo, os mesmos valores por janela intradiária
+// @Tags         trade
+// @Accept       json
+// @Produce      json
+// @Param        ticker       path      string  true  "Código do ticker (ex: PETR4), sem diferenciar maiúsculas"
+// @Param        data_inicio  query     string  false "Data de início no formato YYYY-MM-DD (padrão: 7 pregões até data_fim)"
+// @Param        data_fim     query     string  false "Data de fim, inclusiva, no formato YYYY-MM-DD (padrão: último pregão até hoje)"
+// @Param        interval     query     string  false "Intervalo das janelas intradiárias (padrão: sem janelas)"  Enums(1m, 5m, 15m, 1h)
+// @Success      200          {object}  trade.AveragePrices
+// @Failure      400          {object}  object
+// @Failure      404          {object}  object
+// @Failure      500          {object}  object
+// @Failure      503          {object}  object
+// @Router       /tickers/{ticker}/averages [get]
+func (ctrl *Controller) GetAveragePrices(ctx *gin.Context) {
+	startDate, err := parseDateQuery(ctx, "data_inicio")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	endDate, err := parseDateQuery(ctx, "data_fim")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ticker := ctx.Param("ticker")
+	ctrl.logger.Info("getting average prices", zap.String("ticker", ticker))
+	result, err := ctrl.service.GetAveragePrices(ctx.Request.Context(), ticker, startDate, endDate, trade.CandleInterval(ctx.Query("interval")))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctrl.respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, result)
 }
+
+// Formats of the candles endpoint.
+const (
+	candleFormatJSON     = "json"
+	candleFormatColumnar = "columnar"
+)
+
+// GetCandles godoc
+// @Summary      Obtém candles intradiários de um ticker
+// @Description  Retorna os candles (abertura, máxima, mínima, fechamento, volume e quantidade de negócios) de um ticker em um pregão, por intervalo. Com format=columnar, a resposta é um trade.CandleColumns, com um array por campo
+// @Tags         trade
+// @Accept       json
+// @Produce      json
+// @Param        ticker    path      string  true   "Código do ticker (ex: PETR4), sem diferenciar maiúsculas"
+// @Param        data      query     string  false  "Data do pregão no formato YYYY-MM-DD (padrão: último pregão até hoje)"
+// @Param        interval  query     string  false  "Intervalo dos candles"  Enums(1m, 5m, 15m, 1h)  default(5m)
+// @Param        format    query     string  false  "Formato da resposta"    Enums(json, columnar)  default(json)
+// @Success      200       {object}  trade.CandleSeries
+// @Failure      400       {object}  object
+// @Failure      404       {object}  object
+// @Failure      500       {object}  object
+// @Failure      503       {object}  object
+// @Router       /tickers/{ticker}/candles [get]
+func (ctrl *Controller) GetCandles(ctx *gin.Context) {
+	date, err := parseDateQuery(ctx, "data")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := ctx.DefaultQuery("format", candleFormatJSON)
+	if format != candleFormatJSON && format != candleFormatColumnar {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid format %q, use json or columnar", format)})
+		return
+	}
+
+	ticker := ctx.Param("ticker")
+	ctrl.logger.Info("getting candles", zap.String("ticker", ticker))
+	series, err := ctrl.service.GetCandles(ctx.Request.Context(), ticker, date, trade.CandleInterval(ctx.Query("interval")))
+	if err != nil {
+		ctrl.respondError(ctx, err)
+		return
+	}
+
+	if format == candleFormatColumnar {
+		ctx.JSON(http.StatusOK, series.Columns())
+		return
+	}
+	ctx.JSON(http.StatusOK, series)
+}
+
+// AggregateRequest is the body of the batch aggregation endpoint.
+type AggregateRequest struct {
+	Tickers    []string `json:"tickers" example:"PETR4,VALE3,ITUB4"`
+	DataInicio string   `json:"data_inicio,omitempty" example:"2025-07-29"`
+	DataFim    string   `json:"data_fim,omitempty" example:"2025-08-06"`
+}
+
+// AggregateTrades godoc
+// @Summary      Obtém dados agregados de vários tickers
+// @Description  Retorna os dados agregados de uma lista de tickers no mesmo período, em uma única consulta. Tickers sem negócios no período são listados em not_found
+// @Tags         trade
+// @Accept       json
+// @Produce      json
+// @Param        request  body      AggregateRequest  true  "Tickers (até 200) e período, com datas no formato YYYY-MM-DD e os mesmos padrões de GET /trades"
+// @Success      200      {object}  trade.AggregatedBatch
+// @Failure      400      {object}  object
+// @Failure      500      {object}  object
+// @Failure      503      {object}  object
+// @Router       /trades/aggregate [post]
+func (ctrl *Controller) AggregateTrades(ctx *gin.Context) {
+	var req AggregateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	startDate, err := parseDate("data_inicio", req.DataInicio)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	endDate, err := parseDate("data_fim", req.DataFim)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctrl.logger.Info("getting batch aggregated data", zap.Int("tickers", len(req.Tickers)))
+	result, err := ctrl.service.GetAggregatedDataBatch(ctx.Request.Context(), req.Tickers, startDate, endDate)
+	if err != nil {
+		ctrl.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// parseDateQuery reads an optional YYYY-MM-DD query parameter.
+func parseDateQuery(ctx *gin.Context, name string) (*time.Time, error) {
+	return parseDate(name, ctx.Query(name))
+}
+
+// parseBoolQuery parses an optional boolean query parameter, false when missing.
+func parseBoolQuery(ctx *gin.Context, name string) (bool, error) {
+	value := ctx.Query(name)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value", name)
+	}
+
+	return b, nil
+}
+
+// parseDate parses an optional YYYY-MM-DD date named name.
+func parseDate(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s format", name)
+	}
+
+	return &date, nil
+}
+
+// respondError answers with the status code matching the kind of a service
+// error. Only client errors echo the error message; server errors are logged
+// and answered with the status text, so internal details are not exposed.
+func (ctrl *Controller) respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, trade.ErrValidation):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, trade.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusInternalServerError
+	if errors.Is(err, trade.ErrUnavailable) {
+		status = http.StatusServiceUnavailable
+	}
+	ctrl.logger.Error("request error", zap.String("path", ctx.FullPath()), zap.Error(err))
+
+	ctx.JSON(status, gin.H{"error": strings.ToLower(http.StatusText(status))})
+}
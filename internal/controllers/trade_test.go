@@ -2,14 +2,17 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gurodrigues-dev/b3-reader/trade"
 	"github.com/gurodrigues-dev/b3-reader/trade/mocks"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"go.uber.org/zap"
@@ -18,6 +21,10 @@ import (
 func setupRouter(ctrl *Controller) *gin.Engine {
 	r := gin.New()
 	r.GET("/trade", ctrl.GetTrade)
+	r.POST("/trades/aggregate", ctrl.AggregateTrades)
+	r.GET("/tickers/:ticker/daily", ctrl.GetDailyStats)
+	r.GET("/tickers/:ticker/candles", ctrl.GetCandles)
+	r.GET("/tickers/:ticker/averages", ctrl.GetAveragePrices)
 	return r
 }
 
@@ -38,6 +45,78 @@ func TestController_GetTrade(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "ticker is required")
 	})
 
+	t.Run("invalid ticker return 400", func(t *testing.T) {
+		ctx := t.Context()
+		ctrl := NewController(nil, zap.NewNop())
+		router := setupRouter(ctrl)
+
+		req, _ := http.NewRequestWithContext(ctx, "GET", "/trade?ticker=PETR-4", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid ticker")
+	})
+
+	t.Run("normalizes the ticker", func(t *testing.T) {
+		ctx := t.Context()
+		ctrlMock := gomock.NewController(t)
+		defer ctrlMock.Finish()
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		ctrl := NewController(mockSvc, zap.NewNop())
+		router := setupRouter(ctrl)
+
+		mockSvc.
+			EXPECT().
+			GetAggregatedData(gomock.Any(), "PETR4F", gomock.Nil(), gomock.Nil(), false).
+			Return(&trade.AggregatedData{Ticker: "PETR4F"}, nil)
+
+		req, _ := http.NewRequestWithContext(ctx, "GET", "/trade?ticker=+petr4f+", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("includes the standard lot when asked", func(t *testing.T) {
+		ctx := t.Context()
+		ctrlMock := gomock.NewController(t)
+		defer ctrlMock.Finish()
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		ctrl := NewController(mockSvc, zap.NewNop())
+		router := setupRouter(ctrl)
+
+		mockSvc.
+			EXPECT().
+			GetAggregatedData(gomock.Any(), "PETR4F", gomock.Nil(), gomock.Nil(), true).
+			Return(&trade.AggregatedData{Ticker: "PETR4F"}, nil)
+
+		req, _ := http.NewRequestWithContext(ctx, "GET", "/trade?ticker=PETR4F&incluir_lote_padrao=true", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid incluir_lote_padrao return 400", func(t *testing.T) {
+		ctx := t.Context()
+		ctrl := NewController(nil, zap.NewNop())
+		router := setupRouter(ctrl)
+
+		req, _ := http.NewRequestWithContext(ctx, "GET", "/trade?ticker=PETR4F&incluir_lote_padrao=sim", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid incluir_lote_padrao value")
+	})
+
 	t.Run("invalid data_inicio return 400", func(t *testing.T) {
 		ctx := t.Context()
 		ctrl := NewController(nil, zap.NewNop())
@@ -52,6 +131,20 @@ func TestController_GetTrade(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "invalid data_inicio format")
 	})
 
+	t.Run("invalid data_fim return 400", func(t *testing.T) {
+		ctx := t.Context()
+		ctrl := NewController(nil, zap.NewNop())
+		router := setupRouter(ctrl)
+
+		req, _ := http.NewRequestWithContext(ctx, "GET", "/trade?ticker=PETR4&data_fim=2024-13-01", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid data_fim format")
+	})
+
 	t.Run("service error, return 500", func(t *testing.T) {
 		ctx := t.Context()
 		ctrlMock := gomock.NewController(t)
@@ -62,19 +155,57 @@ func TestController_GetTrade(t *testing.T) {
 		router := setupRouter(ctrl)
 
 		startDate := time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC)
+		endDate := time.Date(2024, 8, 23, 0, 0, 0, 0, time.UTC)
 
 		mockSvc.
 			EXPECT().
-			GetAggregatedData(gomock.Any(), "VALE3", &startDate).
+			GetAggregatedData(gomock.Any(), "VALE3", &startDate, &endDate, false).
 			Return(nil, assert.AnError)
 
-		req, _ := http.NewRequestWithContext(ctx, "GET", "/trade?ticker=VALE3&data_inicio=2024-08-16", nil)
+		req, _ := http.NewRequestWithContext(ctx, "GET", "/trade?ticker=VALE3&data_inicio=2024-08-16&data_fim=2024-08-23", nil)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
-		assert.Contains(t, w.Body.String(), "assert.AnError")
+		assert.JSONEq(t, `{"error":"internal server error"}`, w.Body.String())
+		assert.NotContains(t, w.Body.String(), "assert.AnError")
+	})
+
+	t.Run("classified service errors", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			err    error
+			status int
+			body   string
+		}{
+			{"not found return 404", &trade.NotFoundError{Ticker: "VALE3", StartDate: time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC)}, http.StatusNotFound, "no trades found for ticker VALE3 from 2024-08-12 to 2024-08-16"},
+			{"unavailable return 503", fmt.Errorf("%w: database down", trade.ErrUnavailable), http.StatusServiceUnavailable, "service unavailable"},
+			{"validation return 400", fmt.Errorf("%w: bad input", trade.ErrValidation), http.StatusBadRequest, "validation error: bad input"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctrlMock := gomock.NewController(t)
+				mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+				ctrl := NewController(mockSvc, zap.NewNop())
+				router := setupRouter(ctrl)
+
+				mockSvc.
+					EXPECT().
+					GetAggregatedData(gomock.Any(), "VALE3", gomock.Nil(), gomock.Nil(), false).
+					Return(nil, tt.err)
+
+				req, _ := http.NewRequestWithContext(t.Context(), "GET", "/trade?ticker=VALE3", nil)
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, tt.status, w.Code)
+				assert.JSONEq(t, fmt.Sprintf(`{"error":%q}`, tt.body), w.Body.String())
+			})
+		}
 	})
 
 	t.Run("successfully call, return 200", func(t *testing.T) {
@@ -88,11 +219,17 @@ func TestController_GetTrade(t *testing.T) {
 
 		mockSvc.
 			EXPECT().
-			GetAggregatedData(gomock.Any(), "ITUB4", gomock.Nil()).
+			GetAggregatedData(gomock.Any(), "ITUB4", gomock.Nil(), gomock.Nil(), false).
 			Return(&trade.AggregatedData{
-				Ticker:         "ITUB4",
-				MaxRangeValue:  12.34,
-				MaxDailyVolume: 500,
+				Ticker:             "ITUB4",
+				MaxRangeValue:      decimal.RequireFromString("12.34"),
+				MinRangeValue:      decimal.RequireFromString("11.9"),
+				OpenPrice:          decimal.RequireFromString("12.01"),
+				ClosePrice:         decimal.RequireFromString("12.30"),
+				MaxDailyVolume:     500,
+				MaxDailyVolumeDate: time.Date(2024, 8, 14, 0, 0, 0, 0, time.UTC),
+				TotalVolume:        1800,
+				TradeCount:         42,
 			}, nil)
 
 		req, _ := http.NewRequestWithContext(ctx, "GET", "/trade?ticker=ITUB4", nil)
@@ -103,14 +240,337 @@ func TestController_GetTrade(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 
 		assert.Contains(t, w.Body.String(), `"ticker":"ITUB4"`)
-		assert.Contains(t, w.Body.String(), `"max_range_value":12.34`)
+		assert.Contains(t, w.Body.String(), `"max_range_value":"12.34"`)
 		assert.Contains(t, w.Body.String(), `"max_daily_volume":500`)
+		assert.Contains(t, w.Body.String(), `"max_daily_volume_date":"2024-08-14T00:00:00Z"`)
+		assert.Contains(t, w.Body.String(), `"min_range_value":"11.9"`)
+		assert.Contains(t, w.Body.String(), `"open_price":"12.01"`)
+		assert.Contains(t, w.Body.String(), `"close_price":"12.3"`)
+		assert.Contains(t, w.Body.String(), `"total_volume":1800`)
+		assert.Contains(t, w.Body.String(), `"trade_count":42`)
 
 		var resp trade.AggregatedData
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		assert.NoError(t, err)
 		assert.Equal(t, "ITUB4", resp.Ticker)
-		assert.Equal(t, 12.34, resp.MaxRangeValue)
+		assert.True(t, decimal.RequireFromString("12.34").Equal(resp.MaxRangeValue))
 		assert.Equal(t, 500, resp.MaxDailyVolume)
 	})
 }
+
+func TestController_AggregateTrades(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	post := func(t *testing.T, ctrl *Controller, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequestWithContext(t.Context(), "POST", "/trades/aggregate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		setupRouter(ctrl).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("invalid body return 400", func(t *testing.T) {
+		w := post(t, NewController(nil, zap.NewNop()), `{"tickers": "PETR4"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid request body")
+	})
+
+	t.Run("invalid data_fim return 400", func(t *testing.T) {
+		w := post(t, NewController(nil, zap.NewNop()), `{"tickers": ["PETR4"], "data_fim": "06/08/2025"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid data_fim format")
+	})
+
+	t.Run("validation error return 400", func(t *testing.T) {
+		ctrlMock := gomock.NewController(t)
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		mockSvc.
+			EXPECT().
+			GetAggregatedDataBatch(gomock.Any(), []string{"PETR4", "PETR-4"}, gomock.Nil(), gomock.Nil()).
+			Return(nil, fmt.Errorf("%w: invalid ticker", trade.ErrValidation))
+
+		w := post(t, NewController(mockSvc, zap.NewNop()), `{"tickers": ["PETR4", "PETR-4"]}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid ticker")
+	})
+
+	t.Run("successfully call, return 200", func(t *testing.T) {
+		ctrlMock := gomock.NewController(t)
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		startDate := time.Date(2025, 7, 29, 0, 0, 0, 0, time.UTC)
+		endDate := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+
+		mockSvc.
+			EXPECT().
+			GetAggregatedDataBatch(gomock.Any(), []string{"PETR4", "XPTO3"}, &startDate, &endDate).
+			Return(&trade.AggregatedBatch{
+				StartDate: startDate,
+				EndDate:   endDate,
+				Data: []trade.AggregatedData{
+					{Ticker: "PETR4", MaxRangeValue: decimal.RequireFromString("31.5"), TradeCount: 10},
+				},
+				NotFound: []string{"XPTO3"},
+			}, nil)
+
+		w := post(t, NewController(mockSvc, zap.NewNop()), `{"tickers": ["PETR4", "XPTO3"], "data_inicio": "2025-07-29", "data_fim": "2025-08-06"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp trade.AggregatedBatch
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		if assert.Len(t, resp.Data, 1) {
+			assert.Equal(t, "PETR4", resp.Data[0].Ticker)
+			assert.Equal(t, "31.5", resp.Data[0].MaxRangeValue.String())
+		}
+		assert.Equal(t, []string{"XPTO3"}, resp.NotFound)
+		assert.Equal(t, endDate, resp.EndDate)
+	})
+}
+
+func TestController_GetDailyStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	get := func(t *testing.T, ctrl *Controller, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequestWithContext(t.Context(), "GET", url, nil)
+		w := httptest.NewRecorder()
+		setupRouter(ctrl).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("invalid data_inicio return 400", func(t *testing.T) {
+		w := get(t, NewController(nil, zap.NewNop()), "/tickers/PETR4/daily?data_inicio=2025-02-30")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid data_inicio format")
+	})
+
+	t.Run("ticker without trades return 404", func(t *testing.T) {
+		ctrlMock := gomock.NewController(t)
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		mockSvc.
+			EXPECT().
+			GetDailyStats(gomock.Any(), "xpto3", gomock.Nil(), gomock.Nil()).
+			Return(nil, &trade.NotFoundError{Ticker: "XPTO3"})
+
+		w := get(t, NewController(mockSvc, zap.NewNop()), "/tickers/xpto3/daily")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "no trades found for ticker XPTO3")
+	})
+
+	t.Run("successfully call, return 200", func(t *testing.T) {
+		ctrlMock := gomock.NewController(t)
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		startDate := time.Date(2025, 8, 5, 0, 0, 0, 0, time.UTC)
+		endDate := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+
+		mockSvc.
+			EXPECT().
+			GetDailyStats(gomock.Any(), "PETR4", &startDate, &endDate).
+			Return(&trade.DailySeries{
+				Ticker:    "PETR4",
+				StartDate: startDate,
+				EndDate:   endDate,
+				Sessions: []trade.DailyStats{{
+					Date:       endDate,
+					Open:       decimal.RequireFromString("36.12"),
+					High:       decimal.RequireFromString("37.05"),
+					Low:        decimal.RequireFromString("35.8"),
+					Close:      decimal.RequireFromString("36.9"),
+					VWAP:       decimal.RequireFromString("36.4721553"),
+					Volume:     9840100,
+					TradeCount: 30512,
+				}},
+			}, nil)
+
+		w := get(t, NewController(mockSvc, zap.NewNop()), "/tickers/PETR4/daily?data_inicio=2025-08-05&data_fim=2025-08-06")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"date":"2025-08-06T00:00:00Z"`)
+		assert.Contains(t, w.Body.String(), `"open":"36.12"`)
+		assert.Contains(t, w.Body.String(), `"vwap":"36.4721553"`)
+		assert.Contains(t, w.Body.String(), `"volume":9840100`)
+		assert.Contains(t, w.Body.String(), `"trade_count":30512`)
+	})
+}
+
+func TestController_GetCandles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	get := func(t *testing.T, ctrl *Controller, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequestWithContext(t.Context(), "GET", url, nil)
+		w := httptest.NewRecorder()
+		setupRouter(ctrl).ServeHTTP(w, req)
+		return w
+	}
+
+	date := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+	saoPaulo := time.FixedZone("BRT", -3*60*60)
+	series := &trade.CandleSeries{
+		Ticker:   "PETR4",
+		Date:     date,
+		Interval: trade.CandleInterval1m,
+		Candles: []trade.Candle{{
+			Time:       time.Date(2025, 8, 6, 10, 0, 0, 0, saoPaulo),
+			Open:       decimal.RequireFromString("36.12"),
+			High:       decimal.RequireFromString("36.2"),
+			Low:        decimal.RequireFromString("36.05"),
+			Close:      decimal.RequireFromString("36.18"),
+			Volume:     184300,
+			TradeCount: 612,
+		}},
+	}
+
+	t.Run("invalid data return 400", func(t *testing.T) {
+		w := get(t, NewController(nil, zap.NewNop()), "/tickers/PETR4/candles?data=06/08/2025")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid data format")
+	})
+
+	t.Run("invalid format return 400", func(t *testing.T) {
+		w := get(t, NewController(nil, zap.NewNop()), "/tickers/PETR4/candles?format=csv")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `invalid format \"csv\"`)
+	})
+
+	t.Run("invalid interval return 400", func(t *testing.T) {
+		ctrlMock := gomock.NewController(t)
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		mockSvc.
+			EXPECT().
+			GetCandles(gomock.Any(), "PETR4", gomock.Nil(), trade.CandleInterval("2m")).
+			Return(nil, fmt.Errorf("%w: invalid interval", trade.ErrValidation))
+
+		w := get(t, NewController(mockSvc, zap.NewNop()), "/tickers/PETR4/candles?interval=2m")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid interval")
+	})
+
+	t.Run("successfully call, return 200", func(t *testing.T) {
+		ctrlMock := gomock.NewController(t)
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		mockSvc.
+			EXPECT().
+			GetCandles(gomock.Any(), "PETR4", &date, trade.CandleInterval1m).
+			Return(series, nil)
+
+		w := get(t, NewController(mockSvc, zap.NewNop()), "/tickers/PETR4/candles?data=2025-08-06&interval=1m")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"interval":"1m"`)
+		assert.Contains(t, w.Body.String(), `"time":"2025-08-06T10:00:00-03:00"`)
+		assert.Contains(t, w.Body.String(), `"open":"36.12"`)
+		assert.Contains(t, w.Body.String(), `"volume":184300`)
+	})
+
+	t.Run("columnar format, return 200", func(t *testing.T) {
+		ctrlMock := gomock.NewController(t)
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		mockSvc.
+			EXPECT().
+			GetCandles(gomock.Any(), "PETR4", &date, trade.CandleInterval1m).
+			Return(series, nil)
+
+		w := get(t, NewController(mockSvc, zap.NewNop()), "/tickers/PETR4/candles?data=2025-08-06&interval=1m&format=columnar")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, []any{float64(1754485200)}, body["t"])
+		assert.Equal(t, []any{"36.12"}, body["o"])
+		assert.Equal(t, []any{"36.18"}, body["c"])
+		assert.Equal(t, []any{float64(184300)}, body["v"])
+		assert.Equal(t, []any{float64(612)}, body["n"])
+		assert.NotContains(t, body, "candles")
+	})
+}
+
+func TestController_GetAveragePrices(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	get := func(t *testing.T, ctrl *Controller, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequestWithContext(t.Context(), "GET", url, nil)
+		w := httptest.NewRecorder()
+		setupRouter(ctrl).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("invalid data_fim return 400", func(t *testing.T) {
+		w := get(t, NewController(nil, zap.NewNop()), "/tickers/PETR4/averages?data_fim=2025-13-01")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid data_fim format")
+	})
+
+	t.Run("ticker without trades return 404", func(t *testing.T) {
+		ctrlMock := gomock.NewController(t)
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		mockSvc.
+			EXPECT().
+			GetAveragePrices(gomock.Any(), "xpto3", gomock.Nil(), gomock.Nil(), trade.CandleInterval("")).
+			Return(nil, &trade.NotFoundError{Ticker: "XPTO3"})
+
+		w := get(t, NewController(mockSvc, zap.NewNop()), "/tickers/xpto3/averages")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "no trades found for ticker XPTO3")
+	})
+
+	t.Run("successfully call, return 200", func(t *testing.T) {
+		ctrlMock := gomock.NewController(t)
+		mockSvc := mocks.NewMockUsecase(ctrlMock)
+
+		date := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+		saoPaulo := time.FixedZone("BRT", -3*60*60)
+
+		mockSvc.
+			EXPECT().
+			GetAveragePrices(gomock.Any(), "PETR4", &date, &date, trade.CandleInterval1h).
+			Return(&trade.AveragePrices{
+				Ticker:    "PETR4",
+				StartDate: date,
+				EndDate:   date,
+				Interval:  trade.CandleInterval1h,
+				Sessions: []trade.SessionAverages{{
+					Date: date,
+					AveragePrice: trade.AveragePrice{
+						VWAP:       decimal.RequireFromString("36.4721553"),
+						TWAP:       decimal.RequireFromString("36.45102311"),
+						Volume:     9840100,
+						TradeCount: 30512,
+					},
+					Windows: []trade.WindowAverages{{
+						Time: time.Date(2025, 8, 6, 10, 0, 0, 0, saoPaulo),
+						AveragePrice: trade.AveragePrice{
+							VWAP:       decimal.RequireFromString("36.2"),
+							TWAP:       decimal.RequireFromString("36.21"),
+							Volume:     1200,
+							TradeCount: 7,
+						},
+					}},
+				}},
+			}, nil)
+
+		w := get(t, NewController(mockSvc, zap.NewNop()), "/tickers/PETR4/averages?data_inicio=2025-08-06&data_fim=2025-08-06&interval=1h")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"interval":"1h"`)
+		assert.Contains(t, w.Body.String(), `"vwap":"36.4721553","twap":"36.45102311","volume":9840100,"trade_count":30512`)
+		assert.Contains(t, w.Body.String(), `"windows":[{"time":"2025-08-06T10:00:00-03:00","vwap":"36.2","twap":"36.21"`)
+	})
+}
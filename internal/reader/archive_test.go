@@ -0,0 +1,144 @@
+package reader
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const archiveContent = "col1;col2\n1;2\n"
+
+func readAll(t *testing.T, r *CSVReader, file File) [][]string {
+	t.Helper()
+
+	recCh, errCh := r.Read(t.Context(), file)
+
+	var rows [][]string
+	for {
+		select {
+		case chunk, ok := <-recCh:
+			if !ok {
+				return rows
+			}
+			rows = append(rows, chunk.Rows...)
+		case err, ok := <-errCh:
+			if ok {
+				t.Fatalf("not expect error: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestCSVReader_Zip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "trades.zip")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	_, err := zw.Create("empty/")
+	assert.NoError(t, err)
+	for _, name := range []string{"a.csv", "b.csv"} {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(archiveContent))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, os.WriteFile(file, buf.Bytes(), 0600))
+
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+
+	r := NewCSVReader(file, ';', -1, 100, zap.NewNop())
+
+	files, err := r.List(t.Context())
+
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 2) {
+		t.FailNow()
+	}
+	assert.Equal(t, File{
+		Path:    file,
+		Entry:   "b.csv",
+		Name:    "trades.zip/b.csv",
+		Size:    14,
+		ModTime: info.ModTime(),
+		Sep:     ';',
+	}, files[1])
+	assert.Equal(t, [][]string{{"1", "2"}}, readAll(t, r, files[0]))
+
+	checksum, err := r.Checksum(t.Context(), files[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "48de832b808ed7757f902c01b3340263670d7c1d2e6eed9989e4aab031d5b676", checksum)
+}
+
+func TestCSVReader_Gzip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "trades.csv.gz")
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err := gw.Write([]byte(archiveContent))
+	assert.NoError(t, err)
+	assert.NoError(t, gw.Close())
+	assert.NoError(t, os.WriteFile(file, buf.Bytes(), 0600))
+
+	r := NewCSVReader(file, ';', -1, 100, zap.NewNop())
+
+	files, err := r.List(t.Context())
+
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, "trades.csv.gz", files[0].Name)
+	checksum, err := r.Checksum(t.Context(), files[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "48de832b808ed7757f902c01b3340263670d7c1d2e6eed9989e4aab031d5b676", checksum, "checksum of the decompressed content")
+	assert.Equal(t, [][]string{{"1", "2"}}, readAll(t, r, files[0]))
+}
+
+func TestCSVReader_Zstd(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "trades.csv.zst")
+
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	assert.NoError(t, err)
+	_, err = zw.Write([]byte(archiveContent))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, os.WriteFile(file, buf.Bytes(), 0600))
+
+	r := NewCSVReader(file, ';', -1, 100, zap.NewNop())
+
+	files, err := r.List(t.Context())
+
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, [][]string{{"1", "2"}}, readAll(t, r, files[0]))
+}
+
+func TestCSVReader_Gzip_Corrupted(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bad.csv.gz")
+	assert.NoError(t, os.WriteFile(file, []byte(archiveContent), 0600))
+
+	r := NewCSVReader(file, ';', -1, 100, zap.NewNop())
+
+	files, err := r.List(t.Context())
+	assert.NoError(t, err, "files are only opened when read")
+
+	_, err = r.Checksum(t.Context(), files[0])
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "open gzip error")
+}
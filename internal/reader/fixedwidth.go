@@ -0,0 +1,44 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// readLines streams the lines of a fixed-width file to chunksChan, each one as a
+// row with a single field, never holding more than chunkSize rows in memory.
+func (r *CSVReader) readLines(ctx context.Context, file File, chunksChan chan<- Chunk) error {
+	f, err := open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	offset := 0
+	rows := make([][]string, 0, r.chunkSize)
+	for scanner.Scan() {
+		rows = append(rows, []string{strings.TrimSuffix(scanner.Text(), "\r")})
+		if len(rows) < r.chunkSize {
+			continue
+		}
+
+		if err := sendChunk(ctx, chunksChan, Chunk{File: file.Path, Offset: offset, Rows: rows}); err != nil {
+			return err
+		}
+		offset += len(rows)
+		rows = make([][]string, 0, r.chunkSize)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("file line read error: %w", err)
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return sendChunk(ctx, chunksChan, Chunk{File: file.Path, Offset: offset, Rows: rows})
+}
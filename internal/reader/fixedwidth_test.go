@@ -0,0 +1,35 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestCSVReader_Read_FixedWidth(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "COTAHIST_A2024.TXT")
+	err := os.WriteFile(file, []byte("00HEADER; not split\r\n01LINE 1\r\n01LINE 2\r\n99TRAILER\r\n"), 0600)
+	assert.NoError(t, err)
+
+	r := NewCSVReader(file, ';', -1, 3, zap.NewNop())
+
+	recCh, errCh := r.Read(t.Context(), File{Path: file, Format: FormatFixedWidth})
+
+	var chunks []Chunk
+	for chunk := range recCh {
+		chunks = append(chunks, chunk)
+	}
+	assert.NoError(t, <-errCh)
+
+	if !assert.Len(t, chunks, 2) {
+		t.FailNow()
+	}
+	assert.Nil(t, chunks[0].Header)
+	assert.Equal(t, 0, chunks[0].Offset)
+	assert.Equal(t, [][]string{{"00HEADER; not split"}, {"01LINE 1"}, {"01LINE 2"}}, chunks[0].Rows)
+	assert.Equal(t, 3, chunks[1].Offset)
+	assert.Equal(t, [][]string{{"99TRAILER"}}, chunks[1].Rows)
+}
@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	reader "github.com/gurodrigues-dev/b3-reader/internal/reader"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,17 +41,47 @@ func (m *MockReader) EXPECT() *MockReaderMockRecorder {
 	return m.recorder
 }
 
+// Checksum mocks base method.
+func (m *MockReader) Checksum(ctx context.Context, file reader.File) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Checksum", ctx, file)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Checksum indicates an expected call of Checksum.
+func (mr *MockReaderMockRecorder) Checksum(ctx, file any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checksum", reflect.TypeOf((*MockReader)(nil).Checksum), ctx, file)
+}
+
+// List mocks base method.
+func (m *MockReader) List(ctx context.Context) ([]reader.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx)
+	ret0, _ := ret[0].([]reader.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockReaderMockRecorder) List(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockReader)(nil).List), ctx)
+}
+
 // Read mocks base method.
-func (m *MockReader) Read(ctx context.Context) (<-chan [][]string, <-chan error) {
+func (m *MockReader) Read(ctx context.Context, file reader.File) (<-chan reader.Chunk, <-chan error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Read", ctx)
-	ret0, _ := ret[0].(<-chan [][]string)
+	ret := m.ctrl.Call(m, "Read", ctx, file)
+	ret0, _ := ret[0].(<-chan reader.Chunk)
 	ret1, _ := ret[1].(<-chan error)
 	return ret0, ret1
 }
 
 // Read indicates an expected call of Read.
-func (mr *MockReaderMockRecorder) Read(ctx any) *gomock.Call {
+func (mr *MockReaderMockRecorder) Read(ctx, file any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockReader)(nil).Read), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockReader)(nil).Read), ctx, file)
 }
@@ -1,121 +1,318 @@
 package reader
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+// File describes an input file found by a Reader. ZIP archives are described
+// by one File per entry; .gz and .zst files are decompressed while read.
+type File struct {
+	Path string
+	// Entry is the name of the file inside the ZIP archive at Path, if any.
+	Entry string
+	// Name identifies the file across runs: its path relative to the configured folder, or its base name for a single file.
+	Name string
+	// Size is the size on disk, or the uncompressed size for ZIP entries.
+	Size int64
+	// ModTime is the modification time on disk, of the archive for ZIP entries.
+	ModTime time.Time
+	// Sep is the field separator of the file, used to rebuild its raw lines.
+	Sep rune
+	// Format tells how the rows of the file are read. It is left to the caller
+	// of Read, which knows the layout of the file.
+	Format Format
+}
+
+// Format is the way the rows of a file are split.
+type Format int
+
+const (
+	// FormatCSV files have a header row and fields split by a separator.
+	FormatCSV Format = iota
+	// FormatFixedWidth files have no header and fields at fixed positions. Each
+	// line is read as a row with a single field.
+	FormatFixedWidth
+)
+
+// Chunk is a bounded block of consecutive data rows read from a single file.
+type Chunk struct {
+	// File is the path of the file the rows were read from.
+	File string
+	// Header is the first row of a CSV file, naming its columns. It is never part of Rows.
+	Header []string
+	// Offset is the position of the first row of Rows within the file, starting at 0.
+	// In CSV files the header is at offset 0, so the first data row is at offset 1.
+	Offset int
+	Rows   [][]string
+	// Invalid is a row that could not be read, such as one with a bare or
+	// unbalanced quote. It is at the position right after Rows and ends the chunk.
+	Invalid *InvalidRow
+}
+
+// InvalidRow is a row of a file that could not be split into fields.
+type InvalidRow struct {
+	// Line is the raw text of the row, without its line terminator, truncated
+	// to its first 4096 bytes.
+	Line string
+	Err  error
+}
+
 type Reader interface {
-	// Use read to read a folder of CSV files or a single CSV. It sends columns and rows to the expected channel.
-	Read(ctx context.Context) (<-chan [][]string, <-chan error)
+	// Use list to find the files of a folder of CSV files or a single CSV, plain or compressed.
+	List(ctx context.Context) ([]File, error)
+	// Use read to read a single file. It streams rows to the expected channel in chunks of bounded size.
+	Read(ctx context.Context, file File) (<-chan Chunk, <-chan error)
+	// Use checksum to compute the SHA-256 of the decompressed content of a single file.
+	Checksum(ctx context.Context, file File) (string, error)
 }
 
 type CSVReader struct {
-	path    string
-	sep     rune
-	records int
-	logger  *zap.Logger
+	path      string
+	sep       rune
+	records   int
+	chunkSize int
+	logger    *zap.Logger
 }
 
-func NewCSVReader(path string, sep rune, rec int, logger *zap.Logger) *CSVReader {
+func NewCSVReader(path string, sep rune, rec, chunkSize int, logger *zap.Logger) *CSVReader {
 	return &CSVReader{
-		path:    path,
-		sep:     sep,
-		records: rec,
-		logger:  logger,
+		path:      path,
+		sep:       sep,
+		records:   rec,
+		chunkSize: chunkSize,
+		logger:    logger,
+	}
+}
+
+func (r *CSVReader) List(ctx context.Context) ([]File, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("access path error: %w", err)
+	}
+
+	if !info.IsDir() {
+		return r.describe(r.path, info.Name(), info)
+	}
+
+	var files []File
+	err = filepath.Walk(r.path, func(filePath string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		name, err := filepath.Rel(r.path, filePath)
+		if err != nil {
+			return err
+		}
+
+		described, err := r.describe(filePath, filepath.ToSlash(name), info)
+		if err != nil {
+			return err
+		}
+		files = append(files, described...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list files in path error: %w", err)
 	}
+
+	return files, nil
 }
 
-func (r *CSVReader) Read(ctx context.Context) (<-chan [][]string, <-chan error) {
-	recordsChan := make(chan [][]string)
+func (r *CSVReader) Read(ctx context.Context, file File) (<-chan Chunk, <-chan error) {
+	chunksChan := make(chan Chunk)
 	errChan := make(chan error)
 
 	go func() {
-		defer close(recordsChan)
+		defer close(chunksChan)
 		defer close(errChan)
 
-		info, err := os.Stat(r.path)
-		if err != nil {
-			errChan <- fmt.Errorf("access path error: %w", err)
+		if r.chunkSize <= 0 {
+			errChan <- fmt.Errorf("chunk size must be greater than 0")
 			return
 		}
 
-		if info.IsDir() {
-			r.readDir(ctx, recordsChan, errChan)
-			return
+		r.logger.Info("reading file", zap.String("file", file.Name))
+		read := r.readFile
+		if file.Format == FormatFixedWidth {
+			read = r.readLines
+		}
+		if err := read(ctx, file, chunksChan); err != nil && ctx.Err() == nil {
+			errChan <- fmt.Errorf("read file error %s: %w", file.Path, err)
 		}
-
-		r.readSingleFile(ctx, recordsChan, errChan)
 	}()
 
-	return recordsChan, errChan
+	return chunksChan, errChan
 }
 
-func (r *CSVReader) readFile(filePath string) ([][]string, error) {
-	f, err := os.Open(filePath)
+// utf8BOM is written by some tools at the start of CSV files exported from spreadsheets.
+const utf8BOM = "\ufeff"
+
+// readFile streams the rows of a file to chunksChan, never holding more than chunkSize rows in memory.
+// The first row is taken as the header and sent along with every chunk.
+func (r *CSVReader) readFile(ctx context.Context, file File, chunksChan chan<- Chunk) error {
+	f, err := open(file)
 	if err != nil {
-		return nil, fmt.Errorf("open file error: %w", err)
+		return err
 	}
 	defer f.Close()
 
-	reader := csv.NewReader(f)
+	lines := bufio.NewReader(f)
+	feeder := &lineFeeder{}
+	reader := csv.NewReader(feeder)
 	reader.Comma = r.sep
 	reader.FieldsPerRecord = r.records
 
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("file csv read error: %w", err)
+	// next splits the line read into a record. Each line is fed to the CSV
+	// reader on its own, so a row with an unbalanced quote is reported alone
+	// instead of swallowing the rest of the file. Quoted fields cannot span
+	// lines, which B3 files do not use.
+	var buf []byte
+	next := func() ([]string, []byte, error) {
+		for {
+			var err error
+			buf, err = readLine(lines, buf)
+			if len(buf) == 0 || (err != nil && !errors.Is(err, io.EOF)) {
+				return nil, nil, err
+			}
+
+			feeder.line = buf
+			record, err := reader.Read()
+			// Blank lines have no record.
+			if errors.Is(err, io.EOF) {
+				continue
+			}
+			return record, buf, err
+		}
 	}
 
-	return records, nil
-}
+	header, _, err := next()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("file csv read header error: %w", err)
+	}
+	header[0] = strings.TrimPrefix(header[0], utf8BOM)
 
-func (r *CSVReader) readDir(ctx context.Context, recordsChan chan<- [][]string, errChan chan<- error) {
-	err := filepath.Walk(r.path, func(filePath string, info os.FileInfo, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
+	offset := 1
+	rows := make([][]string, 0, r.chunkSize)
+	for {
+		record, line, err := next()
+		if errors.Is(err, io.EOF) {
+			break
 		}
-		if info.IsDir() {
-			return nil
+		// Malformed rows are handed to the caller, which applies its error policy;
+		// the reader resumes at the next line.
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			invalid := &InvalidRow{Line: invalidLine(line), Err: err}
+			chunk := Chunk{File: file.Path, Header: header, Offset: offset, Rows: rows, Invalid: invalid}
+			if err := sendChunk(ctx, chunksChan, chunk); err != nil {
+				return err
+			}
+			offset += len(rows) + 1
+			rows = make([][]string, 0, r.chunkSize)
+			continue
 		}
-
-		r.logger.Info("reading new file", zap.String("file", info.Name()))
-		records, err := r.readFile(filePath)
 		if err != nil {
-			return fmt.Errorf("read file error %s: %w", filePath, err)
+			return fmt.Errorf("file csv read error: %w", err)
 		}
 
-		r.logger.Info("sending columns and rows to channel")
-		select {
-		case recordsChan <- records:
-			return nil
-		case <-ctx.Done():
-			return ctx.Err()
+		rows = append(rows, record)
+		if len(rows) < r.chunkSize {
+			continue
 		}
-	})
 
-	if err != nil {
-		errChan <- fmt.Errorf("list files in path error: %w", err)
+		if err := sendChunk(ctx, chunksChan, Chunk{File: file.Path, Header: header, Offset: offset, Rows: rows}); err != nil {
+			return err
+		}
+		offset += len(rows)
+		rows = make([][]string, 0, r.chunkSize)
+	}
+
+	if len(rows) == 0 {
+		return nil
 	}
+
+	return sendChunk(ctx, chunksChan, Chunk{File: file.Path, Header: header, Offset: offset, Rows: rows})
 }
 
-func (r *CSVReader) readSingleFile(ctx context.Context, recordsChan chan<- [][]string, errChan chan<- error) {
-	r.logger.Info("reading file")
-	records, err := r.readFile(r.path)
-	if err != nil {
-		errChan <- fmt.Errorf("read file error %s: %w", r.path, err)
-		return
+const (
+	// maxLineLength bounds the memory taken by a single line of a CSV file.
+	maxLineLength = 1 << 20
+	// maxInvalidLineLength bounds the raw text kept for a malformed row.
+	maxInvalidLineLength = 4096
+)
+
+// errLineTooLong is returned for lines longer than maxLineLength, which are not
+// rows of any B3 layout.
+var errLineTooLong = fmt.Errorf("line longer than %d bytes", maxLineLength)
+
+// readLine reads the next line of br into buf, with its terminator. It returns
+// io.EOF along with the last line when the file does not end with a newline.
+func readLine(br *bufio.Reader, buf []byte) ([]byte, error) {
+	buf = buf[:0]
+	for {
+		part, err := br.ReadSlice('\n')
+		buf = append(buf, part...)
+		if len(buf) > maxLineLength {
+			return buf[:0], errLineTooLong
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return buf, err
+		}
 	}
+}
+
+// invalidLine returns the raw text of a malformed row without its terminator,
+// truncated to maxInvalidLineLength.
+func invalidLine(line []byte) string {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) > maxInvalidLineLength {
+		line = line[:maxInvalidLineLength]
+	}
+	return string(line)
+}
+
+// lineFeeder hands the CSV reader one line at a time, reporting io.EOF at its end.
+type lineFeeder struct {
+	line []byte
+}
+
+func (lf *lineFeeder) Read(p []byte) (int, error) {
+	if len(lf.line) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, lf.line)
+	lf.line = lf.line[n:]
+	return n, nil
+}
 
-	r.logger.Info("sending columns and rows to channel")
+func sendChunk(ctx context.Context, chunksChan chan<- Chunk, chunk Chunk) error {
 	select {
-	case recordsChan <- records:
+	case chunksChan <- chunk:
+		return nil
 	case <-ctx.Done():
-		return
+		return ctx.Err()
 	}
 }
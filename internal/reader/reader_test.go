@@ -1,8 +1,10 @@
 package reader
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,20 +12,89 @@ import (
 	"go.uber.org/zap"
 )
 
-func TestCSVReader_Read_FileNotExist(t *testing.T) {
+func TestCSVReader_List_FileNotExist(t *testing.T) {
 	logger := zap.NewNop()
-	r := NewCSVReader("not_exists.csv", ';', -1, logger)
+	r := NewCSVReader("not_exists.csv", ';', -1, 100, logger)
 
-	ctx := t.Context()
-	_, errCh := r.Read(ctx)
+	_, err := r.List(t.Context())
 
-	select {
-	case err := <-errCh:
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "access path error")
-	case <-time.After(time.Second):
-		t.Fatal("expected error, but it timed out")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "access path error")
+}
+
+func TestCSVReader_List_SingleFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.csv")
+	err := os.WriteFile(file, []byte("col1;col2\n1;2\n"), 0600)
+	assert.NoError(t, err)
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+
+	logger := zap.NewNop()
+	r := NewCSVReader(file, ';', -1, 100, logger)
+
+	files, err := r.List(t.Context())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []File{{
+		Path:    file,
+		Name:    "test.csv",
+		Size:    14,
+		ModTime: info.ModTime(),
+		Sep:     ';',
+	}}, files)
+}
+
+func TestCSVReader_List_Directory(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	csvDir := filepath.Join(tmpDir, "csvs")
+	err := os.MkdirAll(filepath.Join(csvDir, "sub"), 0755)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(csvDir, "f1.csv"), []byte("a;b\n1;2\n"), 0600)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(csvDir, "sub", "f2.csv"), []byte("a;b\n1;2\n"), 0600)
+	assert.NoError(t, err)
+
+	logger := zap.NewNop()
+	r := NewCSVReader(csvDir, ';', -1, 100, logger)
+
+	files, err := r.List(t.Context())
+
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, "f1.csv", files[0].Name)
+	assert.Equal(t, "sub/f2.csv", files[1].Name)
+}
+
+func TestCSVReader_Checksum(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.csv": "a;b\n1;2\n", "b.csv": "a;b\n1;2\n", "c.csv": "a;b\n3;4\n"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+
+	r := NewCSVReader(dir, ';', -1, 100, zap.NewNop())
+
+	files, err := r.List(t.Context())
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 3) {
+		t.FailNow()
 	}
+
+	checksums := make([]string, len(files))
+	for i, f := range files {
+		checksums[i], err = r.Checksum(t.Context(), f)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, checksums[0], checksums[1], "same content, same checksum")
+	assert.NotEqual(t, checksums[0], checksums[2])
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = r.Checksum(ctx, files[0])
+	assert.ErrorIs(t, err, context.Canceled)
 }
 
 func TestCSVReader_Read_SingleFile_Success(t *testing.T) {
@@ -37,15 +108,17 @@ func TestCSVReader_Read_SingleFile_Success(t *testing.T) {
 	tmpFile.Close()
 
 	logger := zap.NewNop()
-	r := NewCSVReader(tmpFile.Name(), ';', -1, logger)
+	r := NewCSVReader(tmpFile.Name(), ';', -1, 100, logger)
 
 	ctx := t.Context()
-	recCh, errCh := r.Read(ctx)
+	recCh, errCh := r.Read(ctx, File{Path: tmpFile.Name()})
 
 	select {
-	case recs := <-recCh:
-		assert.Equal(t, 3, len(recs))
-		assert.Equal(t, []string{"col1", "col2"}, recs[0])
+	case chunk := <-recCh:
+		assert.Equal(t, 2, len(chunk.Rows))
+		assert.Equal(t, 1, chunk.Offset)
+		assert.Equal(t, []string{"col1", "col2"}, chunk.Header)
+		assert.Equal(t, []string{"1", "2"}, chunk.Rows[0])
 	case err := <-errCh:
 		t.Fatalf("not expect error: %v", err)
 	case <-time.After(time.Second):
@@ -53,61 +126,169 @@ func TestCSVReader_Read_SingleFile_Success(t *testing.T) {
 	}
 }
 
-func TestCSVReader_Read_Directory_Success(t *testing.T) {
-	tmpDir := t.TempDir()
-
-	csvDir := filepath.Join(tmpDir, "csvs")
-	err := os.Mkdir(csvDir, 0755)
-	assert.NoError(t, err)
-
-	file1 := filepath.Join(csvDir, "f1.csv")
-	err = os.WriteFile(file1, []byte("a;b\n1;2\n"), 0600)
-	assert.NoError(t, err)
-
-	file2 := filepath.Join(csvDir, "f2.csv")
-	err = os.WriteFile(file2, []byte("x;y\n3;4\n"), 0600)
+func TestCSVReader_Read_SingleFile_Chunks(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "chunks.csv")
+	err := os.WriteFile(file, []byte("h1;h2\n1;2\n3;4\n5;6\n7;8\n"), 0600)
 	assert.NoError(t, err)
 
 	logger := zap.NewNop()
-	r := NewCSVReader(csvDir, ';', -1, logger)
+	r := NewCSVReader(file, ';', -1, 2, logger)
 
 	ctx := t.Context()
-	recCh, errCh := r.Read(ctx)
+	recCh, errCh := r.Read(ctx, File{Path: file})
 
-	got := 0
+	var chunks []Chunk
 loop:
 	for {
 		select {
-		case recs, ok := <-recCh:
+		case chunk, ok := <-recCh:
 			if !ok {
 				break loop
 			}
-			assert.True(t, len(recs) >= 2)
-			got++
+			chunks = append(chunks, chunk)
 		case err, ok := <-errCh:
 			if ok {
-				t.Logf("ignored error in walk: %v", err)
+				t.Fatalf("not expect error: %v", err)
 			}
 		case <-time.After(time.Second):
 			t.Fatal("timeout")
 		}
 	}
-	assert.Equal(t, 2, got, "expected exactly 2 files read")
+
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, []int{1, 3}, []int{chunks[0].Offset, chunks[1].Offset})
+	assert.Equal(t, [][]string{{"1", "2"}, {"3", "4"}}, chunks[0].Rows)
+	assert.Equal(t, [][]string{{"5", "6"}, {"7", "8"}}, chunks[1].Rows)
+	assert.Equal(t, []string{"h1", "h2"}, chunks[1].Header)
+	assert.Equal(t, file, chunks[1].File)
 }
 
-func TestCSVReader_readFile_Error(t *testing.T) {
+func TestCSVReader_Read_HeaderOnly(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "header.csv")
+	err := os.WriteFile(file, []byte("\ufeffh1;h2\n"), 0600)
+	assert.NoError(t, err)
+
+	r := NewCSVReader(file, ';', -1, 2, zap.NewNop())
+
+	recCh, errCh := r.Read(t.Context(), File{Path: file})
+
+	_, ok := <-recCh
+	assert.False(t, ok, "no chunk is sent without data rows")
+	assert.NoError(t, <-errCh)
+}
+
+func TestCSVReader_Read_StripsBOM(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bom.csv")
+	err := os.WriteFile(file, []byte("\ufeffh1;h2\n1;2\n"), 0600)
+	assert.NoError(t, err)
+
+	r := NewCSVReader(file, ';', -1, 2, zap.NewNop())
+
+	recCh, _ := r.Read(t.Context(), File{Path: file})
+
+	chunk := <-recCh
+	assert.Equal(t, []string{"h1", "h2"}, chunk.Header)
+}
+
+func TestCSVReader_Read_FileNotExist(t *testing.T) {
+	logger := zap.NewNop()
+	r := NewCSVReader("not_exists.csv", ';', -1, 100, logger)
+
+	ctx := t.Context()
+	_, errCh := r.Read(ctx, File{Path: "not_exists.csv"})
+
+	select {
+	case err := <-errCh:
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "open file error")
+	case <-time.After(time.Second):
+		t.Fatal("expected error, but it timed out")
+	}
+}
+
+func TestCSVReader_readFile_InvalidRows(t *testing.T) {
 	logger := zap.NewNop()
-	r := NewCSVReader("fake.csv", ';', 2, logger)
+	r := NewCSVReader("fake.csv", ';', 2, 100, logger)
 
 	tmpFile, err := os.CreateTemp(t.TempDir(), "bad.csv")
 	assert.NoError(t, err)
 	defer os.Remove(tmpFile.Name())
 
-	_, err = tmpFile.Write([]byte("a;b;c\n1;2\n"))
+	_, err = tmpFile.Write([]byte("h1;h2\n1;2\n1;2;3\r\n4;5\n6;x\"y\n7;8\n"))
 	assert.NoError(t, err)
 	tmpFile.Close()
 
-	_, err = r.readFile(tmpFile.Name())
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "file csv read error")
+	chunksChan := make(chan Chunk, 4)
+	err = r.readFile(t.Context(), File{Path: tmpFile.Name()}, chunksChan)
+	assert.NoError(t, err)
+	close(chunksChan)
+
+	var chunks []Chunk
+	for chunk := range chunksChan {
+		chunks = append(chunks, chunk)
+	}
+	if !assert.Len(t, chunks, 3) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, 1, chunks[0].Offset)
+	assert.Equal(t, [][]string{{"1", "2"}}, chunks[0].Rows)
+	assert.Equal(t, "1;2;3", chunks[0].Invalid.Line)
+	assert.ErrorContains(t, chunks[0].Invalid.Err, "wrong number of fields")
+
+	assert.Equal(t, 3, chunks[1].Offset)
+	assert.Equal(t, [][]string{{"4", "5"}}, chunks[1].Rows)
+	assert.Equal(t, "6;x\"y", chunks[1].Invalid.Line)
+	assert.ErrorContains(t, chunks[1].Invalid.Err, "bare \"")
+
+	assert.Equal(t, 5, chunks[2].Offset)
+	assert.Equal(t, [][]string{{"7", "8"}}, chunks[2].Rows)
+	assert.Nil(t, chunks[2].Invalid)
+}
+
+func TestCSVReader_readFile_UnterminatedQuote(t *testing.T) {
+	r := NewCSVReader("fake.csv", ';', 2, 100, zap.NewNop())
+
+	file := filepath.Join(t.TempDir(), "bad.csv")
+	long := "\"" + strings.Repeat("x", 5000)
+	content := "h1;h2\n1;\"2\n3;4\r\n" + long + "\n5;6"
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0600))
+
+	chunksChan := make(chan Chunk, 4)
+	err := r.readFile(t.Context(), File{Path: file}, chunksChan)
+	assert.NoError(t, err)
+	close(chunksChan)
+
+	var chunks []Chunk
+	for chunk := range chunksChan {
+		chunks = append(chunks, chunk)
+	}
+	if !assert.Len(t, chunks, 3) {
+		t.FailNow()
+	}
+
+	// The unterminated quote only takes its own line; the rows after it are read.
+	assert.Empty(t, chunks[0].Rows)
+	assert.Equal(t, `1;"2`, chunks[0].Invalid.Line)
+	assert.ErrorContains(t, chunks[0].Invalid.Err, `extraneous or missing " in quoted-field`)
+
+	assert.Equal(t, 2, chunks[1].Offset)
+	assert.Equal(t, [][]string{{"3", "4"}}, chunks[1].Rows)
+	assert.Len(t, chunks[1].Invalid.Line, maxInvalidLineLength, "the raw text is truncated")
+
+	assert.Equal(t, 4, chunks[2].Offset)
+	assert.Equal(t, [][]string{{"5", "6"}}, chunks[2].Rows)
+	assert.Nil(t, chunks[2].Invalid)
+}
+
+func TestCSVReader_readFile_LineTooLong(t *testing.T) {
+	r := NewCSVReader("fake.csv", ';', -1, 100, zap.NewNop())
+
+	file := filepath.Join(t.TempDir(), "long.csv")
+	assert.NoError(t, os.WriteFile(file, []byte("h1;h2\n"+strings.Repeat("x", maxLineLength+1)), 0600))
+
+	chunksChan := make(chan Chunk, 1)
+	err := r.readFile(t.Context(), File{Path: file}, chunksChan)
+
+	assert.ErrorIs(t, err, errLineTooLong)
 }
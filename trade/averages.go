@@ -0,0 +1,50 @@
+package trade
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// AveragePrice is the volume-weighted (VWAP) and time-weighted (TWAP) average
+// prices of a ticker in a session or intraday window.
+//
+// VWAP is SUM(price × quantity) / SUM(quantity). For TWAP, the price of each
+// trade holds until the next trade of the same session or window, and the last
+// one until the end of the window or the session close: 18:00, or 17:00 while
+// New York observes daylight saving time. Trades after the close hold no time;
+// when the trades span no time, TWAP is the plain average of the prices. Both
+// are rounded to 8 decimal places.
+type AveragePrice struct {
+	VWAP       decimal.Decimal `json:"vwap" swaggertype:"string" example:"36.47215530"`
+	TWAP       decimal.Decimal `json:"twap" swaggertype:"string" example:"36.45102311"`
+	Volume     int64           `json:"volume" example:"9840100"`
+	TradeCount int64           `json:"trade_count" example:"30512"`
+}
+
+// WindowAverages is the average prices of a ticker in an intraday window.
+type WindowAverages struct {
+	// Time is the start of the window, in São Paulo time. Windows are aligned
+	// to the start of the day.
+	Time time.Time `json:"time" example:"2025-08-06T10:05:00-03:00"`
+	AveragePrice
+}
+
+// SessionAverages is the average prices of a ticker in a session, with the
+// windows of the session with trades when an interval is requested.
+type SessionAverages struct {
+	Date time.Time `json:"date" example:"2025-08-06T00:00:00Z"`
+	AveragePrice
+	Windows []WindowAverages `json:"windows,omitempty"`
+}
+
+// AveragePrices is the average prices of a ticker over a period, one entry per
+// session with trades, oldest first.
+type AveragePrices struct {
+	Ticker    string    `json:"ticker"`
+	StartDate time.Time `json:"start_date" example:"2025-07-29T00:00:00Z"`
+	EndDate   time.Time `json:"end_date" example:"2025-08-06T00:00:00Z"`
+	// Interval is the length of the windows, empty when they were not requested.
+	Interval CandleInterval    `json:"interval,omitempty" swaggertype:"string" example:"15m"`
+	Sessions []SessionAverages `json:"sessions"`
+}
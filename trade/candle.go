@@ -0,0 +1,110 @@
+package trade
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CandleInterval is the length of the intraday candles, as accepted by the API.
+type CandleInterval string
+
+const (
+	CandleInterval1m  CandleInterval = "1m"
+	CandleInterval5m  CandleInterval = "5m"
+	CandleInterval15m CandleInterval = "15m"
+	CandleInterval1h  CandleInterval = "1h"
+
+	// DefaultCandleInterval is used when no interval is given.
+	DefaultCandleInterval = CandleInterval5m
+)
+
+var candleIntervals = map[CandleInterval]time.Duration{
+	CandleInterval1m:  time.Minute,
+	CandleInterval5m:  5 * time.Minute,
+	CandleInterval15m: 15 * time.Minute,
+	CandleInterval1h:  time.Hour,
+}
+
+// ParseCandleInterval validates an interval, defaulting to DefaultCandleInterval
+// when empty. Unknown intervals are reported as ErrValidation.
+func ParseCandleInterval(s string) (CandleInterval, error) {
+	if s == "" {
+		return DefaultCandleInterval, nil
+	}
+
+	interval := CandleInterval(s)
+	if _, ok := candleIntervals[interval]; !ok {
+		return "", validationError(fmt.Errorf("invalid interval %q, use 1m, 5m, 15m or 1h", s))
+	}
+
+	return interval, nil
+}
+
+// Duration is the length of the interval.
+func (i CandleInterval) Duration() time.Duration {
+	return candleIntervals[i]
+}
+
+// Candle summarizes the trades of a ticker in an intraday interval.
+type Candle struct {
+	// Time is the start of the interval, in São Paulo time. Intervals are
+	// aligned to the start of the day.
+	Time time.Time `json:"time" example:"2025-08-06T10:05:00-03:00"`
+	// Open and Close are the prices of the first and last trades of the interval.
+	Open       decimal.Decimal `json:"open" swaggertype:"string" example:"36.12"`
+	High       decimal.Decimal `json:"high" swaggertype:"string" example:"36.20"`
+	Low        decimal.Decimal `json:"low" swaggertype:"string" example:"36.05"`
+	Close      decimal.Decimal `json:"close" swaggertype:"string" example:"36.18"`
+	Volume     int64           `json:"volume" example:"184300"`
+	TradeCount int64           `json:"trade_count" example:"612"`
+}
+
+// CandleSeries is the intraday candles of a ticker in a session, one per
+// interval with trades, oldest first.
+type CandleSeries struct {
+	Ticker   string         `json:"ticker"`
+	Date     time.Time      `json:"date" example:"2025-08-06T00:00:00Z"`
+	Interval CandleInterval `json:"interval" swaggertype:"string" example:"5m"`
+	Candles  []Candle       `json:"candles"`
+}
+
+// CandleColumns is the compact columnar form of a CandleSeries: one array per
+// field, all of the same length, with times as Unix seconds.
+type CandleColumns struct {
+	Ticker     string            `json:"ticker"`
+	Date       time.Time         `json:"date" example:"2025-08-06T00:00:00Z"`
+	Interval   CandleInterval    `json:"interval" swaggertype:"string" example:"5m"`
+	Time       []int64           `json:"t"`
+	Open       []decimal.Decimal `json:"o" swaggertype:"array,string"`
+	High       []decimal.Decimal `json:"h" swaggertype:"array,string"`
+	Low        []decimal.Decimal `json:"l" swaggertype:"array,string"`
+	Close      []decimal.Decimal `json:"c" swaggertype:"array,string"`
+	Volume     []int64           `json:"v"`
+	TradeCount []int64           `json:"n"`
+}
+
+// Columns converts the series to its columnar form.
+func (s *CandleSeries) Columns() *CandleColumns {
+	n := len(s.Candles)
+	cols := &CandleColumns{
+		Ticker:     s.Ticker,
+		Date:       s.Date,
+		Interval:   s.Interval,
+		Time:       make([]int64, n),
+		Open:       make([]decimal.Decimal, n),
+		High:       make([]decimal.Decimal, n),
+		Low:        make([]decimal.Decimal, n),
+		Close:      make([]decimal.Decimal, n),
+		Volume:     make([]int64, n),
+		TradeCount: make([]int64, n),
+	}
+	for i, c := range s.Candles {
+		cols.Time[i] = c.Time.Unix()
+		cols.Open[i], cols.High[i], cols.Low[i], cols.Close[i] = c.Open, c.High, c.Low, c.Close
+		cols.Volume[i], cols.TradeCount[i] = c.Volume, c.TradeCount
+	}
+
+	return cols
+}
@@ -0,0 +1,82 @@
+package trade
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gurodrigues-dev/b3-reader/internal/calendar"
+	"github.com/shopspring/decimal"
+)
+
+func TestParseCandleInterval(t *testing.T) {
+	tests := []struct {
+		raw      string
+		want     CandleInterval
+		duration time.Duration
+		wantErr  bool
+	}{
+		{raw: "", want: CandleInterval5m, duration: 5 * time.Minute},
+		{raw: "1m", want: CandleInterval1m, duration: time.Minute},
+		{raw: "15m", want: CandleInterval15m, duration: 15 * time.Minute},
+		{raw: "1h", want: CandleInterval1h, duration: time.Hour},
+		{raw: "2m", wantErr: true},
+		{raw: "60m", wantErr: true},
+		{raw: "1H", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseCandleInterval(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCandleInterval(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrValidation) {
+					t.Errorf("ParseCandleInterval(%q) error = %v, want ErrValidation", tt.raw, err)
+				}
+				return
+			}
+			if got != tt.want || got.Duration() != tt.duration {
+				t.Errorf("ParseCandleInterval(%q) = %s (%s), want %s (%s)", tt.raw, got, got.Duration(), tt.want, tt.duration)
+			}
+		})
+	}
+}
+
+func TestCandleSeries_Columns(t *testing.T) {
+	date := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+	first := time.Date(2025, 8, 6, 10, 0, 0, 0, calendar.SaoPaulo)
+	series := &CandleSeries{
+		Ticker:   "PETR4",
+		Date:     date,
+		Interval: CandleInterval5m,
+		Candles: []Candle{
+			{Time: first, Open: decimal.RequireFromString("36.1"), High: decimal.RequireFromString("36.3"),
+				Low: decimal.RequireFromString("36"), Close: decimal.RequireFromString("36.2"), Volume: 300, TradeCount: 4},
+			{Time: first.Add(10 * time.Minute), Open: decimal.RequireFromString("36.2"), High: decimal.RequireFromString("36.4"),
+				Low: decimal.RequireFromString("36.2"), Close: decimal.RequireFromString("36.4"), Volume: 100, TradeCount: 1},
+		},
+	}
+
+	cols := series.Columns()
+
+	if cols.Ticker != "PETR4" || !cols.Date.Equal(date) || cols.Interval != CandleInterval5m {
+		t.Errorf("Columns() header = %s %s %s", cols.Ticker, cols.Date, cols.Interval)
+	}
+	if len(cols.Time) != 2 || cols.Time[0] != 1754485200 || cols.Time[1] != 1754485800 {
+		t.Errorf("Columns().Time = %v", cols.Time)
+	}
+	if !cols.Open[1].Equal(decimal.RequireFromString("36.2")) || !cols.High[0].Equal(decimal.RequireFromString("36.3")) ||
+		!cols.Low[0].Equal(decimal.RequireFromString("36")) || !cols.Close[1].Equal(decimal.RequireFromString("36.4")) {
+		t.Errorf("Columns() prices = %v %v %v %v", cols.Open, cols.High, cols.Low, cols.Close)
+	}
+	if cols.Volume[0] != 300 || cols.TradeCount[1] != 1 {
+		t.Errorf("Columns() volume = %v, trade count = %v", cols.Volume, cols.TradeCount)
+	}
+
+	empty := (&CandleSeries{Ticker: "PETR4"}).Columns()
+	if empty.Time == nil || len(empty.Time) != 0 {
+		t.Errorf("Columns() of an empty series = %v, want an empty array", empty.Time)
+	}
+}
@@ -0,0 +1,148 @@
+package trade
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Record types of the COTAHIST historical series. Only quotes are loaded; the
+// header and trailer records are skipped.
+const (
+	cotahistTipoHeader  = "00"
+	cotahistTipoCotacao = "01"
+	cotahistTipoTrailer = "99"
+)
+
+// fixedField is a field of a fixed-width record, with the 1-based inclusive
+// positions used by the B3 layout documents.
+type fixedField struct {
+	start, end int
+}
+
+func (f fixedField) of(line string) string {
+	return strings.TrimSpace(line[f.start-1 : f.end])
+}
+
+// Fields of a COTAHIST quote record (TIPREG = 01).
+var (
+	cotahistTipoRegistro     = fixedField{1, 2}
+	cotahistDataPregao       = fixedField{3, 10}
+	cotahistCodigoBDI        = fixedField{11, 12}
+	cotahistCodigoNegociacao = fixedField{13, 24}
+	cotahistTipoMercado      = fixedField{25, 27}
+	cotahistPrecoAbertura    = fixedField{57, 69}
+	cotahistPrecoMaximo      = fixedField{70, 82}
+	cotahistPrecoMinimo      = fixedField{83, 95}
+	cotahistPrecoMedio       = fixedField{96, 108}
+	cotahistPrecoUltimo      = fixedField{109, 121}
+	cotahistTotalNegocios    = fixedField{148, 152}
+	cotahistQuantidadeTotal  = fixedField{153, 170}
+	cotahistVolumeTotal      = fixedField{171, 188}
+	cotahistFatorCotacao     = fixedField{211, 217}
+	cotahistCodigoISIN       = fixedField{231, 242}
+)
+
+// parseCotahist converts lines of a COTAHIST file into daily bars, collecting
+// the lines that fail to parse.
+func parseCotahist(records [][]string, offset int) ([]DailyBar, []rowError) {
+	bars := make([]DailyBar, 0, len(records))
+	var rowErrs []rowError
+
+	for i, record := range records {
+		row := offset + i + 1
+		bar, ok, err := parseCotahistRecord(record, row)
+		if err != nil {
+			rowErrs = append(rowErrs, rowError{row: row, record: record, err: err})
+			continue
+		}
+		if ok {
+			bars = append(bars, bar)
+		}
+	}
+
+	return bars, rowErrs
+}
+
+// parseCotahistRecord parses a quote record, reporting false for the header and
+// trailer records.
+func parseCotahistRecord(record []string, row int) (DailyBar, bool, error) {
+	line := record[0]
+	if len(line) < cotahistCodigoISIN.end {
+		if strings.HasPrefix(line, cotahistTipoHeader) || strings.HasPrefix(line, cotahistTipoTrailer) {
+			return DailyBar{}, false, nil
+		}
+		return DailyBar{}, false, fmt.Errorf("parse error at row %d: expected at least %d characters, got %d", row, cotahistCodigoISIN.end, len(line))
+	}
+
+	switch tipo := cotahistTipoRegistro.of(line); tipo {
+	case cotahistTipoCotacao:
+	case cotahistTipoHeader, cotahistTipoTrailer:
+		return DailyBar{}, false, nil
+	default:
+		return DailyBar{}, false, fmt.Errorf("parse error tipo_registro at row %d: unsupported value %s", row, tipo)
+	}
+
+	dataPregao, err := time.Parse("20060102", cotahistDataPregao.of(line))
+	if err != nil {
+		return DailyBar{}, false, fmt.Errorf("parse error data_pregao at row %d: %w", row, err)
+	}
+
+	tipoMercado, err := strconv.Atoi(cotahistTipoMercado.of(line))
+	if err != nil {
+		return DailyBar{}, false, fmt.Errorf("parse error tipo_mercado at row %d: %w", row, err)
+	}
+
+	bar := DailyBar{
+		DataPregao:        dataPregao,
+		CodigoBDI:         cotahistCodigoBDI.of(line),
+		CodigoInstrumento: cotahistCodigoNegociacao.of(line),
+		TipoMercado:       tipoMercado,
+		ISIN:              cotahistCodigoISIN.of(line),
+	}
+
+	prices := []struct {
+		name  string
+		field fixedField
+		dest  *decimal.Decimal
+	}{
+		{"preco_abertura", cotahistPrecoAbertura, &bar.PrecoAbertura},
+		{"preco_maximo", cotahistPrecoMaximo, &bar.PrecoMaximo},
+		{"preco_minimo", cotahistPrecoMinimo, &bar.PrecoMinimo},
+		{"preco_medio", cotahistPrecoMedio, &bar.PrecoMedio},
+		{"preco_fechamento", cotahistPrecoUltimo, &bar.PrecoFechamento},
+		{"volume_total", cotahistVolumeTotal, &bar.VolumeTotal},
+	}
+	for _, p := range prices {
+		v, err := parseImpliedCents(p.field.of(line))
+		if err != nil {
+			return DailyBar{}, false, fmt.Errorf("parse error %s at row %d: %w", p.name, row, err)
+		}
+		*p.dest = v
+	}
+
+	if bar.NumeroNegocios, err = strconv.Atoi(cotahistTotalNegocios.of(line)); err != nil {
+		return DailyBar{}, false, fmt.Errorf("parse error numero_negocios at row %d: %w", row, err)
+	}
+	if bar.QuantidadeTotal, err = strconv.ParseInt(cotahistQuantidadeTotal.of(line), 10, 64); err != nil {
+		return DailyBar{}, false, fmt.Errorf("parse error quantidade_total at row %d: %w", row, err)
+	}
+	if bar.FatorCotacao, err = strconv.Atoi(cotahistFatorCotacao.of(line)); err != nil {
+		return DailyBar{}, false, fmt.Errorf("parse error fator_cotacao at row %d: %w", row, err)
+	}
+
+	return bar, true, nil
+}
+
+// parseImpliedCents parses a COTAHIST value with two implied decimal places.
+func parseImpliedCents(value string) (decimal.Decimal, error) {
+	cents, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	return decimal.New(cents, -2), nil
+}
@@ -0,0 +1,88 @@
+package trade
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// cotahistLine builds a COTAHIST quote record of ticker with the given closing price in cents.
+func cotahistLine(ticker string, closeCents int) string {
+	var b strings.Builder
+	b.WriteString("01")                           // TIPREG
+	b.WriteString("20240816")                     // DATA DO PREGAO
+	b.WriteString("02")                           // CODBDI
+	fmt.Fprintf(&b, "%-12s", ticker)              // CODNEG
+	b.WriteString("010")                          // TPMERC
+	fmt.Fprintf(&b, "%-12s", "PETROBRAS")         // NOMRES
+	fmt.Fprintf(&b, "%-10s", "PN      N2")        // ESPECI
+	b.WriteString("   ")                          // PRAZOT
+	b.WriteString("R$  ")                         // MODREF
+	fmt.Fprintf(&b, "%013d", 3650)                // PREABE
+	fmt.Fprintf(&b, "%013d", 3712)                // PREMAX
+	fmt.Fprintf(&b, "%013d", 3640)                // PREMIN
+	fmt.Fprintf(&b, "%013d", 3688)                // PREMED
+	fmt.Fprintf(&b, "%013d", closeCents)          // PREULT
+	fmt.Fprintf(&b, "%013d", 3700)                // PREOFC
+	fmt.Fprintf(&b, "%013d", 3701)                // PREOFV
+	fmt.Fprintf(&b, "%05d", 54321)                // TOTNEG
+	fmt.Fprintf(&b, "%018d", 41234500)            // QUATOT
+	fmt.Fprintf(&b, "%018d", int64(152077236000)) // VOLTOT
+	fmt.Fprintf(&b, "%013d", 0)                   // PREEXE
+	b.WriteString("0")                            // INDOPC
+	b.WriteString("99991231")                     // DATVEN
+	fmt.Fprintf(&b, "%07d", 1)                    // FATCOT
+	fmt.Fprintf(&b, "%013d", 0)                   // PTOEXE
+	b.WriteString("BRPETRACNPR6")                 // CODISI
+	b.WriteString("123")                          // DISMES
+	return b.String()
+}
+
+func TestParseCotahist(t *testing.T) {
+	records := [][]string{
+		{"00COTAHIST.2024BOVESPA 20240816"},
+		{cotahistLine("PETR4", 3705)},
+		{strings.Replace(cotahistLine("VALE3", 6000), "0000000006000", "00000000060x0", 1)},
+		{"01short"},
+		{"99COTAHIST.2024BOVESPA 2024081600000000003"},
+	}
+
+	bars, rowErrs := parseCotahist(records, 0)
+
+	if len(bars) != 1 {
+		t.Fatalf("expected 1 bar, obtained %d", len(bars))
+	}
+	want := DailyBar{
+		DataPregao:        time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC),
+		CodigoBDI:         "02",
+		CodigoInstrumento: "PETR4",
+		TipoMercado:       10,
+		PrecoAbertura:     decimal.RequireFromString("36.50"),
+		PrecoMaximo:       decimal.RequireFromString("37.12"),
+		PrecoMinimo:       decimal.RequireFromString("36.40"),
+		PrecoMedio:        decimal.RequireFromString("36.88"),
+		PrecoFechamento:   decimal.RequireFromString("37.05"),
+		NumeroNegocios:    54321,
+		QuantidadeTotal:   41234500,
+		VolumeTotal:       decimal.RequireFromString("1520772360.00"),
+		FatorCotacao:      1,
+		ISIN:              "BRPETRACNPR6",
+	}
+	if !reflect.DeepEqual(bars[0], want) {
+		t.Errorf("expected %+v, obtained %+v", want, bars[0])
+	}
+
+	if len(rowErrs) != 2 {
+		t.Fatalf("expected 2 row errors, obtained %v", rowErrs)
+	}
+	if rowErrs[0].row != 3 || !strings.Contains(rowErrs[0].err.Error(), "parse error preco_fechamento at row 3") {
+		t.Errorf("unexpected row error %v", rowErrs[0].err)
+	}
+	if rowErrs[1].row != 4 || !strings.Contains(rowErrs[1].err.Error(), "expected at least 242 characters") {
+		t.Errorf("unexpected row error %v", rowErrs[1].err)
+	}
+}
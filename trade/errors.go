@@ -0,0 +1,60 @@
+package trade
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by the Service fall into the kinds below, checked with
+// errors.Is, so callers such as the API can react to them without matching
+// messages.
+var (
+	// ErrValidation reports invalid input: a malformed request or file.
+	ErrValidation = errors.New("validation error")
+	// ErrNotFound reports that there is no data for the request.
+	ErrNotFound = errors.New("not found")
+	// ErrUnavailable reports that a dependency, such as the database, failed.
+	ErrUnavailable = errors.New("unavailable")
+	// ErrConflict reports that a write was aborted because of a concurrent
+	// one, such as a deadlock between two files, and may be retried.
+	ErrConflict = errors.New("conflict")
+)
+
+// kindError tags an error with its kind, keeping its message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+func validationError(err error) error {
+	return &kindError{kind: ErrValidation, err: err}
+}
+
+func unavailableError(err error) error {
+	return &kindError{kind: ErrUnavailable, err: err}
+}
+
+// NotFoundError is returned when a ticker has no trades in the requested period.
+type NotFoundError struct {
+	Ticker    string
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("no trades found for ticker %s from %s to %s",
+		e.Ticker, e.StartDate.Format("2006-01-02"), e.EndDate.Format("2006-01-02"))
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
@@ -0,0 +1,142 @@
+package trade
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/gurodrigues-dev/b3-reader/internal/reader"
+)
+
+// chunkParser turns the rows of a chunk into the records to be written,
+// collecting the rows that fail to parse.
+type chunkParser func(records [][]string, offset int) (writeBatch, []rowError)
+
+// layout describes a file layout published by B3: how its files are recognised
+// and how their rows are parsed and stored.
+type layout struct {
+	name string
+	// filePattern matches the base name of the files of the layout.
+	filePattern *regexp.Regexp
+	// columns is the schema of the layout, used to recognise files by their
+	// header when the name does not match any pattern.
+	columns []string
+	// format is how the rows of the files are read. Fixed-width files have no
+	// header, so they are only recognised by name.
+	format reader.Format
+
+	newParser func(header []string) (chunkParser, error)
+}
+
+// tradeIntradayLayout loads the intraday trades (negócios listados) into the trades table.
+var tradeIntradayLayout = layout{
+	name:        "trade-intraday",
+	filePattern: regexp.MustCompile(`(?i)(TradeIntraday|NEGOCIOSAVISTA)`),
+	columns:     tradeIntradayHeader,
+	newParser: func(header []string) (chunkParser, error) {
+		cols, err := newColumnMap(tradeIntradayHeader, header)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(records [][]string, offset int) (writeBatch, []rowError) {
+			trades, rowErrs := parseTrade(records, offset, cols)
+			trades, corrections, cancellations := splitUpdates(trades)
+			return writeBatch{trades: trades, corrections: corrections, cancellations: cancellations}, rowErrs
+		}, nil
+	},
+}
+
+// instrumentsLayout loads the instrument registry (cadastro de instrumentos) into the instruments table.
+var instrumentsLayout = layout{
+	name:        "instruments",
+	filePattern: regexp.MustCompile(`(?i)^InstrumentsConsolidatedFile`),
+	columns:     instrumentsHeader,
+	newParser: func(header []string) (chunkParser, error) {
+		cols, err := newColumnMap(instrumentsHeader, header)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(records [][]string, offset int) (writeBatch, []rowError) {
+			instruments, rowErrs := parseInstruments(records, offset, cols)
+			return writeBatch{instruments: instruments}, rowErrs
+		}, nil
+	},
+}
+
+// cotahistLayout loads the COTAHIST fixed-width historical quotes into the daily_bars table.
+var cotahistLayout = layout{
+	name:        "cotahist",
+	filePattern: regexp.MustCompile(`(?i)^COTAHIST_`),
+	format:      reader.FormatFixedWidth,
+	newParser: func(_ []string) (chunkParser, error) {
+		return func(records [][]string, offset int) (writeBatch, []rowError) {
+			bars, rowErrs := parseCotahist(records, offset)
+			return writeBatch{bars: bars}, rowErrs
+		}, nil
+	},
+}
+
+// registry selects the layout of each ingested file.
+type registry struct {
+	layouts []layout
+}
+
+// newRegistry creates a registry of layouts. File name patterns are tried in
+// the order the layouts are given.
+func newRegistry(layouts ...layout) *registry {
+	return &registry{layouts: layouts}
+}
+
+// defaultRegistry holds every layout the ingestor knows how to load.
+func defaultRegistry() *registry {
+	return newRegistry(tradeIntradayLayout, instrumentsLayout, cotahistLayout)
+}
+
+// byName finds the layout of a file by its name alone. It returns nil when no
+// file name pattern matches.
+func (r *registry) byName(fileName string) *layout {
+	base := path.Base(fileName)
+	for i := range r.layouts {
+		if p := r.layouts[i].filePattern; p != nil && p.MatchString(base) {
+			return &r.layouts[i]
+		}
+	}
+
+	return nil
+}
+
+// detect finds the layout of a file, first by its name and then by its header:
+// the layout sharing the most columns with the header wins, as long as at least
+// half of its columns are present. Requiring only half lets a file whose
+// header lost or renamed a column still be reported by the column mapping.
+func (r *registry) detect(fileName string, header []string) (*layout, error) {
+	if l := r.byName(fileName); l != nil {
+		return l, nil
+	}
+
+	names := make(map[string]bool, len(header))
+	for _, name := range header {
+		names[normalizeColumn(name)] = true
+	}
+
+	var found *layout
+	best := 0
+	for i, l := range r.layouts {
+		score := 0
+		for _, col := range l.columns {
+			if names[normalizeColumn(col)] {
+				score++
+			}
+		}
+		if score > best && score*2 >= len(l.columns) {
+			found, best = &r.layouts[i], score
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("unknown layout for file %s", fileName)
+	}
+
+	return found, nil
+}
@@ -0,0 +1,99 @@
+package trade
+
+import (
+	"testing"
+)
+
+func TestRegistry_detect(t *testing.T) {
+	r := defaultRegistry()
+
+	tests := []struct {
+		name     string
+		fileName string
+		header   []string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "by trade intraday file name",
+			fileName: "input/16-08-2024_NEGOCIOSAVISTA.txt",
+			header:   []string{"anything"},
+			want:     tradeIntradayLayout.name,
+		},
+		{
+			name:     "by instruments file name inside an archive",
+			fileName: "cadastro.zip/InstrumentsConsolidatedFile_20240816_1.csv",
+			want:     instrumentsLayout.name,
+		},
+		{
+			name:     "by COTAHIST file name",
+			fileName: "COTAHIST_A2024.ZIP/COTAHIST_A2024.TXT",
+			want:     cotahistLayout.name,
+		},
+		{
+			name:     "by trade intraday header",
+			fileName: "trades.csv",
+			header:   tradeIntradayHeader,
+			want:     tradeIntradayLayout.name,
+		},
+		{
+			name:     "by partial instruments header with extra columns",
+			fileName: "cadastro.csv",
+			header:   append([]string{"Asst", "AsstDesc"}, instrumentsHeader[3:]...),
+			want:     instrumentsLayout.name,
+		},
+		{
+			name:     "by header missing a column",
+			fileName: "trades.csv",
+			header:   tradeIntradayHeader[1:],
+			want:     tradeIntradayLayout.name,
+		},
+		{
+			name:     "unknown layout",
+			fileName: "notes.csv",
+			header:   []string{"DataReferencia", "Nota"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.detect(tt.fileName, tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, but=%v", tt.wantErr, err)
+			}
+			if !tt.wantErr && got.name != tt.want {
+				t.Errorf("expected layout %s, obtained %s", tt.want, got.name)
+			}
+		})
+	}
+}
+
+func TestInstrumentsLayout_Parser(t *testing.T) {
+	header := []string{"RptDt", "TckrSymb", "Asst", "AsstDesc", "SgmtNm", "MktNm", "SctyCtgyNm", "XprtnDt", "ISIN", "CrpnNm"}
+	parse, err := instrumentsLayout.newParser(header)
+	if err != nil {
+		t.Fatalf("not expect error: %v", err)
+	}
+
+	batch, rowErrs := parse([][]string{
+		{"2024-08-16", "PETR4", "PETR", "PETROBRAS", "CASH", "EQUITY-CASH", "SHARES", "", "BRPETRACNPR6", "PETROLEO BRASILEIRO S.A. PETROBRAS"},
+		{"2024-08-16", "PETRI300", "PETR", "PETROBRAS", "EQUITY DERIVATIVE", "OPTIONS", "OPTION ON EQUITIES", "2024-09-20", "BRPETRACNPR6", ""},
+		{"16/08/2024", "VALE3", "VALE", "VALE", "CASH", "EQUITY-CASH", "SHARES", "", "BRVALEACNOR0", "VALE S.A."},
+	}, 1)
+
+	if len(batch.instruments) != 2 {
+		t.Fatalf("expected 2 instruments, obtained %d", len(batch.instruments))
+	}
+	if len(rowErrs) != 1 || rowErrs[0].row != 4 {
+		t.Fatalf("expected a row error at row 4, obtained %v", rowErrs)
+	}
+
+	petr4 := batch.instruments[0]
+	if petr4.CodigoInstrumento != "PETR4" || petr4.ISIN != "BRPETRACNPR6" || petr4.DataVencimento != nil {
+		t.Errorf("unexpected instrument %+v", petr4)
+	}
+	if option := batch.instruments[1]; option.DataVencimento == nil || option.DataVencimento.Format("2006-01-02") != "2024-09-20" {
+		t.Errorf("expected expiration date 2024-09-20, obtained %v", option.DataVencimento)
+	}
+}
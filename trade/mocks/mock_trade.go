@@ -42,6 +42,51 @@ func (m *MockWriter) EXPECT() *MockWriterMockRecorder {
 	return m.recorder
 }
 
+// CancelTrades mocks base method.
+func (m *MockWriter) CancelTrades(ctx context.Context, cancellations []trade.Trade) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelTrades", ctx, cancellations)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelTrades indicates an expected call of CancelTrades.
+func (mr *MockWriterMockRecorder) CancelTrades(ctx, cancellations any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelTrades", reflect.TypeOf((*MockWriter)(nil).CancelTrades), ctx, cancellations)
+}
+
+// CorrectTrades mocks base method.
+func (m *MockWriter) CorrectTrades(ctx context.Context, corrections []trade.Trade) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CorrectTrades", ctx, corrections)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CorrectTrades indicates an expected call of CorrectTrades.
+func (mr *MockWriterMockRecorder) CorrectTrades(ctx, corrections any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CorrectTrades", reflect.TypeOf((*MockWriter)(nil).CorrectTrades), ctx, corrections)
+}
+
+// ReplaceBatch mocks base method.
+func (m *MockWriter) ReplaceBatch(ctx context.Context, trades []trade.Trade) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceBatch", ctx, trades)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplaceBatch indicates an expected call of ReplaceBatch.
+func (mr *MockWriterMockRecorder) ReplaceBatch(ctx, trades any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceBatch", reflect.TypeOf((*MockWriter)(nil).ReplaceBatch), ctx, trades)
+}
+
 // SaveBatch mocks base method.
 func (m *MockWriter) SaveBatch(ctx context.Context, trades []trade.Trade) (int64, error) {
 	m.ctrl.T.Helper()
@@ -57,6 +102,186 @@ func (mr *MockWriterMockRecorder) SaveBatch(ctx, trades any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBatch", reflect.TypeOf((*MockWriter)(nil).SaveBatch), ctx, trades)
 }
 
+// SaveDailyBars mocks base method.
+func (m *MockWriter) SaveDailyBars(ctx context.Context, bars []trade.DailyBar) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDailyBars", ctx, bars)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveDailyBars indicates an expected call of SaveDailyBars.
+func (mr *MockWriterMockRecorder) SaveDailyBars(ctx, bars any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDailyBars", reflect.TypeOf((*MockWriter)(nil).SaveDailyBars), ctx, bars)
+}
+
+// SaveInstruments mocks base method.
+func (m *MockWriter) SaveInstruments(ctx context.Context, instruments []trade.Instrument) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveInstruments", ctx, instruments)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveInstruments indicates an expected call of SaveInstruments.
+func (mr *MockWriterMockRecorder) SaveInstruments(ctx, instruments any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveInstruments", reflect.TypeOf((*MockWriter)(nil).SaveInstruments), ctx, instruments)
+}
+
+// MockTransactor is a mock of Transactor interface.
+type MockTransactor struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransactorMockRecorder
+	isgomock struct{}
+}
+
+// MockTransactorMockRecorder is the mock recorder for MockTransactor.
+type MockTransactorMockRecorder struct {
+	mock *MockTransactor
+}
+
+// NewMockTransactor creates a new mock instance.
+func NewMockTransactor(ctrl *gomock.Controller) *MockTransactor {
+	mock := &MockTransactor{ctrl: ctrl}
+	mock.recorder = &MockTransactorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransactor) EXPECT() *MockTransactorMockRecorder {
+	return m.recorder
+}
+
+// WithinTransaction mocks base method.
+func (m *MockTransactor) WithinTransaction(ctx context.Context, fn func(context.Context, trade.Writer) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithinTransaction", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithinTransaction indicates an expected call of WithinTransaction.
+func (mr *MockTransactorMockRecorder) WithinTransaction(ctx, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithinTransaction", reflect.TypeOf((*MockTransactor)(nil).WithinTransaction), ctx, fn)
+}
+
+// MockLedger is a mock of Ledger interface.
+type MockLedger struct {
+	ctrl     *gomock.Controller
+	recorder *MockLedgerMockRecorder
+	isgomock struct{}
+}
+
+// MockLedgerMockRecorder is the mock recorder for MockLedger.
+type MockLedgerMockRecorder struct {
+	mock *MockLedger
+}
+
+// NewMockLedger creates a new mock instance.
+func NewMockLedger(ctrl *gomock.Controller) *MockLedger {
+	mock := &MockLedger{ctrl: ctrl}
+	mock.recorder = &MockLedgerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLedger) EXPECT() *MockLedgerMockRecorder {
+	return m.recorder
+}
+
+// FinishIngestedFile mocks base method.
+func (m *MockLedger) FinishIngestedFile(ctx context.Context, file *trade.IngestedFile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishIngestedFile", ctx, file)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinishIngestedFile indicates an expected call of FinishIngestedFile.
+func (mr *MockLedgerMockRecorder) FinishIngestedFile(ctx, file any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishIngestedFile", reflect.TypeOf((*MockLedger)(nil).FinishIngestedFile), ctx, file)
+}
+
+// GetIngestedFile mocks base method.
+func (m *MockLedger) GetIngestedFile(ctx context.Context, fileName string) (*trade.IngestedFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIngestedFile", ctx, fileName)
+	ret0, _ := ret[0].(*trade.IngestedFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIngestedFile indicates an expected call of GetIngestedFile.
+func (mr *MockLedgerMockRecorder) GetIngestedFile(ctx, fileName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIngestedFile", reflect.TypeOf((*MockLedger)(nil).GetIngestedFile), ctx, fileName)
+}
+
+// GetIngestedFileByChecksum mocks base method.
+func (m *MockLedger) GetIngestedFileByChecksum(ctx context.Context, checksum string) (*trade.IngestedFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIngestedFileByChecksum", ctx, checksum)
+	ret0, _ := ret[0].(*trade.IngestedFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIngestedFileByChecksum indicates an expected call of GetIngestedFileByChecksum.
+func (mr *MockLedgerMockRecorder) GetIngestedFileByChecksum(ctx, checksum any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIngestedFileByChecksum", reflect.TypeOf((*MockLedger)(nil).GetIngestedFileByChecksum), ctx, checksum)
+}
+
+// GetStoredSessions mocks base method.
+func (m *MockLedger) GetStoredSessions(ctx context.Context, dataset trade.Dataset, startDate, endDate time.Time) ([]time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStoredSessions", ctx, dataset, startDate, endDate)
+	ret0, _ := ret[0].([]time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStoredSessions indicates an expected call of GetStoredSessions.
+func (mr *MockLedgerMockRecorder) GetStoredSessions(ctx, dataset, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStoredSessions", reflect.TypeOf((*MockLedger)(nil).GetStoredSessions), ctx, dataset, startDate, endDate)
+}
+
+// SaveRejected mocks base method.
+func (m *MockLedger) SaveRejected(ctx context.Context, rejected []trade.RejectedTrade) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRejected", ctx, rejected)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveRejected indicates an expected call of SaveRejected.
+func (mr *MockLedgerMockRecorder) SaveRejected(ctx, rejected any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRejected", reflect.TypeOf((*MockLedger)(nil).SaveRejected), ctx, rejected)
+}
+
+// StartIngestedFile mocks base method.
+func (m *MockLedger) StartIngestedFile(ctx context.Context, file *trade.IngestedFile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartIngestedFile", ctx, file)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StartIngestedFile indicates an expected call of StartIngestedFile.
+func (mr *MockLedgerMockRecorder) StartIngestedFile(ctx, file any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartIngestedFile", reflect.TypeOf((*MockLedger)(nil).StartIngestedFile), ctx, file)
+}
+
 // MockReader is a mock of Reader interface.
 type MockReader struct {
 	ctrl     *gomock.Controller
@@ -82,19 +307,93 @@ func (m *MockReader) EXPECT() *MockReaderMockRecorder {
 }
 
 // GetAggregatedData mocks base method.
-func (m *MockReader) GetAggregatedData(ctx context.Context, ticker string, startDate time.Time) (float64, int, error) {
+func (m *MockReader) GetAggregatedData(ctx context.Context, ticker string, codes []string, startDate, endDate time.Time) (*trade.AggregatedData, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAggregatedData", ctx, ticker, startDate)
-	ret0, _ := ret[0].(float64)
-	ret1, _ := ret[1].(int)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
+	ret := m.ctrl.Call(m, "GetAggregatedData", ctx, ticker, codes, startDate, endDate)
+	ret0, _ := ret[0].(*trade.AggregatedData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetAggregatedData indicates an expected call of GetAggregatedData.
-func (mr *MockReaderMockRecorder) GetAggregatedData(ctx, ticker, startDate any) *gomock.Call {
+func (mr *MockReaderMockRecorder) GetAggregatedData(ctx, ticker, codes, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAggregatedData", reflect.TypeOf((*MockReader)(nil).GetAggregatedData), ctx, ticker, codes, startDate, endDate)
+}
+
+// GetAggregatedDataBatch mocks base method.
+func (m *MockReader) GetAggregatedDataBatch(ctx context.Context, tickers []string, startDate, endDate time.Time) ([]trade.AggregatedData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAggregatedDataBatch", ctx, tickers, startDate, endDate)
+	ret0, _ := ret[0].([]trade.AggregatedData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAggregatedDataBatch indicates an expected call of GetAggregatedDataBatch.
+func (mr *MockReaderMockRecorder) GetAggregatedDataBatch(ctx, tickers, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAggregatedDataBatch", reflect.TypeOf((*MockReader)(nil).GetAggregatedDataBatch), ctx, tickers, startDate, endDate)
+}
+
+// GetCandles mocks base method.
+func (m *MockReader) GetCandles(ctx context.Context, ticker string, date time.Time, interval time.Duration) ([]trade.Candle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCandles", ctx, ticker, date, interval)
+	ret0, _ := ret[0].([]trade.Candle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCandles indicates an expected call of GetCandles.
+func (mr *MockReaderMockRecorder) GetCandles(ctx, ticker, date, interval any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCandles", reflect.TypeOf((*MockReader)(nil).GetCandles), ctx, ticker, date, interval)
+}
+
+// GetDailyStats mocks base method.
+func (m *MockReader) GetDailyStats(ctx context.Context, ticker string, startDate, endDate time.Time) ([]trade.DailyStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDailyStats", ctx, ticker, startDate, endDate)
+	ret0, _ := ret[0].([]trade.DailyStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDailyStats indicates an expected call of GetDailyStats.
+func (mr *MockReaderMockRecorder) GetDailyStats(ctx, ticker, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDailyStats", reflect.TypeOf((*MockReader)(nil).GetDailyStats), ctx, ticker, startDate, endDate)
+}
+
+// GetSessionAverages mocks base method.
+func (m *MockReader) GetSessionAverages(ctx context.Context, ticker string, startDate, endDate time.Time) ([]trade.SessionAverages, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionAverages", ctx, ticker, startDate, endDate)
+	ret0, _ := ret[0].([]trade.SessionAverages)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionAverages indicates an expected call of GetSessionAverages.
+func (mr *MockReaderMockRecorder) GetSessionAverages(ctx, ticker, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionAverages", reflect.TypeOf((*MockReader)(nil).GetSessionAverages), ctx, ticker, startDate, endDate)
+}
+
+// GetWindowAverages mocks base method.
+func (m *MockReader) GetWindowAverages(ctx context.Context, ticker string, startDate, endDate time.Time, interval time.Duration) ([]trade.WindowAverages, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWindowAverages", ctx, ticker, startDate, endDate, interval)
+	ret0, _ := ret[0].([]trade.WindowAverages)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWindowAverages indicates an expected call of GetWindowAverages.
+func (mr *MockReaderMockRecorder) GetWindowAverages(ctx, ticker, startDate, endDate, interval any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAggregatedData", reflect.TypeOf((*MockReader)(nil).GetAggregatedData), ctx, ticker, startDate)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWindowAverages", reflect.TypeOf((*MockReader)(nil).GetWindowAverages), ctx, ticker, startDate, endDate, interval)
 }
 
 // MockRepository is a mock of Repository interface.
@@ -121,20 +420,198 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CancelTrades mocks base method.
+func (m *MockRepository) CancelTrades(ctx context.Context, cancellations []trade.Trade) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelTrades", ctx, cancellations)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelTrades indicates an expected call of CancelTrades.
+func (mr *MockRepositoryMockRecorder) CancelTrades(ctx, cancellations any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelTrades", reflect.TypeOf((*MockRepository)(nil).CancelTrades), ctx, cancellations)
+}
+
+// CorrectTrades mocks base method.
+func (m *MockRepository) CorrectTrades(ctx context.Context, corrections []trade.Trade) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CorrectTrades", ctx, corrections)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CorrectTrades indicates an expected call of CorrectTrades.
+func (mr *MockRepositoryMockRecorder) CorrectTrades(ctx, corrections any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CorrectTrades", reflect.TypeOf((*MockRepository)(nil).CorrectTrades), ctx, corrections)
+}
+
+// FinishIngestedFile mocks base method.
+func (m *MockRepository) FinishIngestedFile(ctx context.Context, file *trade.IngestedFile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishIngestedFile", ctx, file)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinishIngestedFile indicates an expected call of FinishIngestedFile.
+func (mr *MockRepositoryMockRecorder) FinishIngestedFile(ctx, file any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishIngestedFile", reflect.TypeOf((*MockRepository)(nil).FinishIngestedFile), ctx, file)
+}
+
 // GetAggregatedData mocks base method.
-func (m *MockRepository) GetAggregatedData(ctx context.Context, ticker string, startDate time.Time) (float64, int, error) {
+func (m *MockRepository) GetAggregatedData(ctx context.Context, ticker string, codes []string, startDate, endDate time.Time) (*trade.AggregatedData, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAggregatedData", ctx, ticker, startDate)
-	ret0, _ := ret[0].(float64)
-	ret1, _ := ret[1].(int)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
+	ret := m.ctrl.Call(m, "GetAggregatedData", ctx, ticker, codes, startDate, endDate)
+	ret0, _ := ret[0].(*trade.AggregatedData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetAggregatedData indicates an expected call of GetAggregatedData.
-func (mr *MockRepositoryMockRecorder) GetAggregatedData(ctx, ticker, startDate any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetAggregatedData(ctx, ticker, codes, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAggregatedData", reflect.TypeOf((*MockRepository)(nil).GetAggregatedData), ctx, ticker, codes, startDate, endDate)
+}
+
+// GetAggregatedDataBatch mocks base method.
+func (m *MockRepository) GetAggregatedDataBatch(ctx context.Context, tickers []string, startDate, endDate time.Time) ([]trade.AggregatedData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAggregatedDataBatch", ctx, tickers, startDate, endDate)
+	ret0, _ := ret[0].([]trade.AggregatedData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAggregatedDataBatch indicates an expected call of GetAggregatedDataBatch.
+func (mr *MockRepositoryMockRecorder) GetAggregatedDataBatch(ctx, tickers, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAggregatedDataBatch", reflect.TypeOf((*MockRepository)(nil).GetAggregatedDataBatch), ctx, tickers, startDate, endDate)
+}
+
+// GetCandles mocks base method.
+func (m *MockRepository) GetCandles(ctx context.Context, ticker string, date time.Time, interval time.Duration) ([]trade.Candle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCandles", ctx, ticker, date, interval)
+	ret0, _ := ret[0].([]trade.Candle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCandles indicates an expected call of GetCandles.
+func (mr *MockRepositoryMockRecorder) GetCandles(ctx, ticker, date, interval any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCandles", reflect.TypeOf((*MockRepository)(nil).GetCandles), ctx, ticker, date, interval)
+}
+
+// GetDailyStats mocks base method.
+func (m *MockRepository) GetDailyStats(ctx context.Context, ticker string, startDate, endDate time.Time) ([]trade.DailyStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDailyStats", ctx, ticker, startDate, endDate)
+	ret0, _ := ret[0].([]trade.DailyStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDailyStats indicates an expected call of GetDailyStats.
+func (mr *MockRepositoryMockRecorder) GetDailyStats(ctx, ticker, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDailyStats", reflect.TypeOf((*MockRepository)(nil).GetDailyStats), ctx, ticker, startDate, endDate)
+}
+
+// GetIngestedFile mocks base method.
+func (m *MockRepository) GetIngestedFile(ctx context.Context, fileName string) (*trade.IngestedFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIngestedFile", ctx, fileName)
+	ret0, _ := ret[0].(*trade.IngestedFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIngestedFile indicates an expected call of GetIngestedFile.
+func (mr *MockRepositoryMockRecorder) GetIngestedFile(ctx, fileName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIngestedFile", reflect.TypeOf((*MockRepository)(nil).GetIngestedFile), ctx, fileName)
+}
+
+// GetIngestedFileByChecksum mocks base method.
+func (m *MockRepository) GetIngestedFileByChecksum(ctx context.Context, checksum string) (*trade.IngestedFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIngestedFileByChecksum", ctx, checksum)
+	ret0, _ := ret[0].(*trade.IngestedFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIngestedFileByChecksum indicates an expected call of GetIngestedFileByChecksum.
+func (mr *MockRepositoryMockRecorder) GetIngestedFileByChecksum(ctx, checksum any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIngestedFileByChecksum", reflect.TypeOf((*MockRepository)(nil).GetIngestedFileByChecksum), ctx, checksum)
+}
+
+// GetSessionAverages mocks base method.
+func (m *MockRepository) GetSessionAverages(ctx context.Context, ticker string, startDate, endDate time.Time) ([]trade.SessionAverages, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionAverages", ctx, ticker, startDate, endDate)
+	ret0, _ := ret[0].([]trade.SessionAverages)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionAverages indicates an expected call of GetSessionAverages.
+func (mr *MockRepositoryMockRecorder) GetSessionAverages(ctx, ticker, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionAverages", reflect.TypeOf((*MockRepository)(nil).GetSessionAverages), ctx, ticker, startDate, endDate)
+}
+
+// GetStoredSessions mocks base method.
+func (m *MockRepository) GetStoredSessions(ctx context.Context, dataset trade.Dataset, startDate, endDate time.Time) ([]time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStoredSessions", ctx, dataset, startDate, endDate)
+	ret0, _ := ret[0].([]time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStoredSessions indicates an expected call of GetStoredSessions.
+func (mr *MockRepositoryMockRecorder) GetStoredSessions(ctx, dataset, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStoredSessions", reflect.TypeOf((*MockRepository)(nil).GetStoredSessions), ctx, dataset, startDate, endDate)
+}
+
+// GetWindowAverages mocks base method.
+func (m *MockRepository) GetWindowAverages(ctx context.Context, ticker string, startDate, endDate time.Time, interval time.Duration) ([]trade.WindowAverages, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWindowAverages", ctx, ticker, startDate, endDate, interval)
+	ret0, _ := ret[0].([]trade.WindowAverages)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWindowAverages indicates an expected call of GetWindowAverages.
+func (mr *MockRepositoryMockRecorder) GetWindowAverages(ctx, ticker, startDate, endDate, interval any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAggregatedData", reflect.TypeOf((*MockRepository)(nil).GetAggregatedData), ctx, ticker, startDate)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWindowAverages", reflect.TypeOf((*MockRepository)(nil).GetWindowAverages), ctx, ticker, startDate, endDate, interval)
+}
+
+// ReplaceBatch mocks base method.
+func (m *MockRepository) ReplaceBatch(ctx context.Context, trades []trade.Trade) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceBatch", ctx, trades)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplaceBatch indicates an expected call of ReplaceBatch.
+func (mr *MockRepositoryMockRecorder) ReplaceBatch(ctx, trades any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceBatch", reflect.TypeOf((*MockRepository)(nil).ReplaceBatch), ctx, trades)
 }
 
 // SaveBatch mocks base method.
@@ -152,6 +629,79 @@ func (mr *MockRepositoryMockRecorder) SaveBatch(ctx, trades any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBatch", reflect.TypeOf((*MockRepository)(nil).SaveBatch), ctx, trades)
 }
 
+// SaveDailyBars mocks base method.
+func (m *MockRepository) SaveDailyBars(ctx context.Context, bars []trade.DailyBar) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDailyBars", ctx, bars)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveDailyBars indicates an expected call of SaveDailyBars.
+func (mr *MockRepositoryMockRecorder) SaveDailyBars(ctx, bars any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDailyBars", reflect.TypeOf((*MockRepository)(nil).SaveDailyBars), ctx, bars)
+}
+
+// SaveInstruments mocks base method.
+func (m *MockRepository) SaveInstruments(ctx context.Context, instruments []trade.Instrument) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveInstruments", ctx, instruments)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveInstruments indicates an expected call of SaveInstruments.
+func (mr *MockRepositoryMockRecorder) SaveInstruments(ctx, instruments any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveInstruments", reflect.TypeOf((*MockRepository)(nil).SaveInstruments), ctx, instruments)
+}
+
+// SaveRejected mocks base method.
+func (m *MockRepository) SaveRejected(ctx context.Context, rejected []trade.RejectedTrade) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRejected", ctx, rejected)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveRejected indicates an expected call of SaveRejected.
+func (mr *MockRepositoryMockRecorder) SaveRejected(ctx, rejected any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRejected", reflect.TypeOf((*MockRepository)(nil).SaveRejected), ctx, rejected)
+}
+
+// StartIngestedFile mocks base method.
+func (m *MockRepository) StartIngestedFile(ctx context.Context, file *trade.IngestedFile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartIngestedFile", ctx, file)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StartIngestedFile indicates an expected call of StartIngestedFile.
+func (mr *MockRepositoryMockRecorder) StartIngestedFile(ctx, file any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartIngestedFile", reflect.TypeOf((*MockRepository)(nil).StartIngestedFile), ctx, file)
+}
+
+// WithinTransaction mocks base method.
+func (m *MockRepository) WithinTransaction(ctx context.Context, fn func(context.Context, trade.Writer) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithinTransaction", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithinTransaction indicates an expected call of WithinTransaction.
+func (mr *MockRepositoryMockRecorder) WithinTransaction(ctx, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithinTransaction", reflect.TypeOf((*MockRepository)(nil).WithinTransaction), ctx, fn)
+}
+
 // MockUsecase is a mock of Usecase interface.
 type MockUsecase struct {
 	ctrl     *gomock.Controller
@@ -177,26 +727,87 @@ func (m *MockUsecase) EXPECT() *MockUsecaseMockRecorder {
 }
 
 // GetAggregatedData mocks base method.
-func (m *MockUsecase) GetAggregatedData(ctx context.Context, ticker string, startDate *time.Time) (*trade.AggregatedData, error) {
+func (m *MockUsecase) GetAggregatedData(ctx context.Context, ticker string, startDate, endDate *time.Time, includeLot bool) (*trade.AggregatedData, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAggregatedData", ctx, ticker, startDate)
+	ret := m.ctrl.Call(m, "GetAggregatedData", ctx, ticker, startDate, endDate, includeLot)
 	ret0, _ := ret[0].(*trade.AggregatedData)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAggregatedData indicates an expected call of GetAggregatedData.
-func (mr *MockUsecaseMockRecorder) GetAggregatedData(ctx, ticker, startDate any) *gomock.Call {
+func (mr *MockUsecaseMockRecorder) GetAggregatedData(ctx, ticker, startDate, endDate, includeLot any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAggregatedData", reflect.TypeOf((*MockUsecase)(nil).GetAggregatedData), ctx, ticker, startDate, endDate, includeLot)
+}
+
+// GetAggregatedDataBatch mocks base method.
+func (m *MockUsecase) GetAggregatedDataBatch(ctx context.Context, tickers []string, startDate, endDate *time.Time) (*trade.AggregatedBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAggregatedDataBatch", ctx, tickers, startDate, endDate)
+	ret0, _ := ret[0].(*trade.AggregatedBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAggregatedDataBatch indicates an expected call of GetAggregatedDataBatch.
+func (mr *MockUsecaseMockRecorder) GetAggregatedDataBatch(ctx, tickers, startDate, endDate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAggregatedDataBatch", reflect.TypeOf((*MockUsecase)(nil).GetAggregatedDataBatch), ctx, tickers, startDate, endDate)
+}
+
+// GetAveragePrices mocks base method.
+func (m *MockUsecase) GetAveragePrices(ctx context.Context, ticker string, startDate, endDate *time.Time, interval trade.CandleInterval) (*trade.AveragePrices, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAveragePrices", ctx, ticker, startDate, endDate, interval)
+	ret0, _ := ret[0].(*trade.AveragePrices)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAveragePrices indicates an expected call of GetAveragePrices.
+func (mr *MockUsecaseMockRecorder) GetAveragePrices(ctx, ticker, startDate, endDate, interval any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAveragePrices", reflect.TypeOf((*MockUsecase)(nil).GetAveragePrices), ctx, ticker, startDate, endDate, interval)
+}
+
+// GetCandles mocks base method.
+func (m *MockUsecase) GetCandles(ctx context.Context, ticker string, date *time.Time, interval trade.CandleInterval) (*trade.CandleSeries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCandles", ctx, ticker, date, interval)
+	ret0, _ := ret[0].(*trade.CandleSeries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCandles indicates an expected call of GetCandles.
+func (mr *MockUsecaseMockRecorder) GetCandles(ctx, ticker, date, interval any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCandles", reflect.TypeOf((*MockUsecase)(nil).GetCandles), ctx, ticker, date, interval)
+}
+
+// GetDailyStats mocks base method.
+func (m *MockUsecase) GetDailyStats(ctx context.Context, ticker string, startDate, endDate *time.Time) (*trade.DailySeries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDailyStats", ctx, ticker, startDate, endDate)
+	ret0, _ := ret[0].(*trade.DailySeries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDailyStats indicates an expected call of GetDailyStats.
+func (mr *MockUsecaseMockRecorder) GetDailyStats(ctx, ticker, startDate, endDate any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAggregatedData", reflect.TypeOf((*MockUsecase)(nil).GetAggregatedData), ctx, ticker, startDate)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDailyStats", reflect.TypeOf((*MockUsecase)(nil).GetDailyStats), ctx, ticker, startDate, endDate)
 }
 
 // IngestFiles mocks base method.
-func (m *MockUsecase) IngestFiles(ctx context.Context, filePath string) error {
+func (m *MockUsecase) IngestFiles(ctx context.Context, filePath string) (*trade.IngestSummary, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "IngestFiles", ctx, filePath)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(*trade.IngestSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // IngestFiles indicates an expected call of IngestFiles.
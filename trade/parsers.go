@@ -5,61 +5,337 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/gurodrigues-dev/b3-reader/internal/calendar"
+	"github.com/shopspring/decimal"
 )
 
-func parseTrade(records [][]string) ([]Trade, error) {
-	var trades []Trade
+// Columns of the TradeIntraday layout published by B3.
+const (
+	colDataReferencia = iota
+	colCodigoInstrumento
+	colAcaoAtualizacao
+	colPrecoNegocio
+	colQuantidadeNegociada
+	colHoraFechamento
+	colCodigoIdentificadorNegocio
+	colTipoSessaoPregao
+	colDataNegocio
+	colCodigoParticipanteComprador
+	colCodigoParticipanteVendedor
+)
 
-	for i, record := range records {
-		if i == 0 {
-			continue
-		}
+// tradeIntradayHeader is the schema of the TradeIntraday layout: the name of each
+// column in the header of the files.
+var tradeIntradayHeader = []string{
+	colDataReferencia:              "DataReferencia",
+	colCodigoInstrumento:           "CodigoInstrumento",
+	colAcaoAtualizacao:             "AcaoAtualizacao",
+	colPrecoNegocio:                "PrecoNegocio",
+	colQuantidadeNegociada:         "QuantidadeNegociada",
+	colHoraFechamento:              "HoraFechamento",
+	colCodigoIdentificadorNegocio:  "CodigoIdentificadorNegocio",
+	colTipoSessaoPregao:            "TipoSessaoPregao",
+	colDataNegocio:                 "DataNegocio",
+	colCodigoParticipanteComprador: "CodigoParticipanteComprador",
+	colCodigoParticipanteVendedor:  "CodigoParticipanteVendedor",
+}
 
-		dataNegocio, err := time.Parse("2006-01-02", record[8])
-		if err != nil {
-			return nil, fmt.Errorf("parse error data_negocio at row %d: %w", i+1, err)
-		}
+// Columns of the instrument registry (cadastro de instrumentos) published by B3.
+const (
+	colInstrumentDataReferencia = iota
+	colInstrumentCodigo
+	colInstrumentAtivo
+	colInstrumentSegmento
+	colInstrumentMercado
+	colInstrumentCategoria
+	colInstrumentISIN
+	colInstrumentDataVencimento
+	colInstrumentRazaoSocial
+)
 
-		precoNegocioStr := strings.ReplaceAll(record[3], ",", ".")
-		precoNegocio, err := strconv.ParseFloat(precoNegocioStr, 64)
-		if err != nil {
-			return nil, fmt.Errorf("parse error preco_negocio at row %d: %w", i+1, err)
+// instrumentsHeader is the schema of the instrument registry layout.
+var instrumentsHeader = []string{
+	colInstrumentDataReferencia: "RptDt",
+	colInstrumentCodigo:         "TckrSymb",
+	colInstrumentAtivo:          "Asst",
+	colInstrumentSegmento:       "SgmtNm",
+	colInstrumentMercado:        "MktNm",
+	colInstrumentCategoria:      "SctyCtgyNm",
+	colInstrumentISIN:           "ISIN",
+	colInstrumentDataVencimento: "XprtnDt",
+	colInstrumentRazaoSocial:    "CrpnNm",
+}
+
+// columnMap holds the position of each column of a schema within the records
+// of a file, as found in its header.
+type columnMap struct {
+	index []int
+	// width is the minimum number of fields a record must have.
+	width int
+}
+
+// normalizeColumn is the form column names are compared in.
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// newColumnMap maps the columns of schema by name against the header of a file.
+// Names are matched ignoring case and surrounding spaces, and columns outside
+// the schema are ignored.
+func newColumnMap(schema, header []string) (columnMap, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		key := normalizeColumn(name)
+		if _, ok := positions[key]; ok {
+			return columnMap{}, fmt.Errorf("duplicated column %s in header", strings.TrimSpace(name))
 		}
+		positions[key] = i
+	}
 
-		quantidadeStr := strings.ReplaceAll(record[4], ",", "")
-		quantidadeNegociada, err := strconv.Atoi(quantidadeStr)
-		if err != nil {
-			return nil, fmt.Errorf("erro ao analisar quantidade_negociada: %w", err)
+	cols := columnMap{index: make([]int, len(schema))}
+	var missing []string
+	for col, name := range schema {
+		pos, ok := positions[normalizeColumn(name)]
+		if !ok {
+			missing = append(missing, name)
+			continue
 		}
+		cols.index[col] = pos
+		cols.width = max(cols.width, pos+1)
+	}
+	if len(missing) > 0 {
+		return columnMap{}, fmt.Errorf("missing columns in header: %s", strings.Join(missing, ", "))
+	}
 
-		horaFechamento, err := parseHoraFechamento(record[5])
+	return cols, nil
+}
+
+// field returns the value of a column of record.
+func (c columnMap) field(record []string, col int) string {
+	return record[c.index[col]]
+}
+
+// Precision and scale of the trades.preco_negocio column. Prices that do not
+// fit are rejected instead of being rounded by the database.
+const (
+	precoNegocioPrecision = 20
+	precoNegocioScale     = 8
+)
+
+// checkNumeric reports whether d fits a NUMERIC(precision, scale) column without rounding.
+func checkNumeric(d decimal.Decimal, precision, scale int32) error {
+	if !d.Equal(d.Truncate(scale)) {
+		return fmt.Errorf("value %s has more than %d decimal places", d, scale)
+	}
+	if d.Abs().GreaterThanOrEqual(decimal.New(1, precision-scale)) {
+		return fmt.Errorf("value %s has more than %d integer digits", d, precision-scale)
+	}
+
+	return nil
+}
+
+// rowError describes a row that could not be parsed.
+type rowError struct {
+	row    int
+	record []string
+	err    error
+}
+
+// parseTrade converts CSV rows into trades, reading each column at the position
+// given by cols and collecting the rows that fail to parse. Offset is the
+// position of records[0] within its file and is only used to report the failing row.
+func parseTrade(records [][]string, offset int, cols columnMap) ([]Trade, []rowError) {
+	trades := make([]Trade, 0, len(records))
+	var rowErrs []rowError
+
+	for i, record := range records {
+		row := offset + i + 1
+		trade, err := parseTradeRecord(record, row, cols)
 		if err != nil {
-			return nil, fmt.Errorf("parse error hora_fechamento at row %d: %w", i+1, err)
+			rowErrs = append(rowErrs, rowError{row: row, record: record, err: err})
+			continue
 		}
 
-		trade := Trade{
-			DataNegocio:         dataNegocio,
-			CodigoInstrumento:   record[1],
-			PrecoNegocio:        precoNegocio,
-			QuantidadeNegociada: quantidadeNegociada,
-			HoraFechamento:      horaFechamento,
-			CreatedAt:           time.Now(),
+		trades = append(trades, trade)
+	}
+
+	return trades, rowErrs
+}
+
+func parseTradeRecord(record []string, row int, cols columnMap) (Trade, error) {
+	if len(record) < cols.width {
+		return Trade{}, fmt.Errorf("parse error at row %d: expected %d columns, got %d", row, cols.width, len(record))
+	}
+
+	dataReferencia, err := time.Parse("2006-01-02", cols.field(record, colDataReferencia))
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error data_referencia at row %d: %w", row, err)
+	}
+
+	ticker, err := ParseTicker(cols.field(record, colCodigoInstrumento))
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error codigo_instrumento at row %d: %w", row, err)
+	}
+
+	acaoAtualizacao, err := strconv.Atoi(cols.field(record, colAcaoAtualizacao))
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error acao_atualizacao at row %d: %w", row, err)
+	}
+	switch acaoAtualizacao {
+	case AcaoAtualizacaoNovo, AcaoAtualizacaoCorrecao, AcaoAtualizacaoCancelamento:
+	default:
+		return Trade{}, fmt.Errorf("parse error acao_atualizacao at row %d: unsupported value %d", row, acaoAtualizacao)
+	}
+
+	dataNegocio, err := time.Parse("2006-01-02", cols.field(record, colDataNegocio))
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error data_negocio at row %d: %w", row, err)
+	}
+
+	precoNegocio, err := decimal.NewFromString(strings.ReplaceAll(cols.field(record, colPrecoNegocio), ",", "."))
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error preco_negocio at row %d: %w", row, err)
+	}
+	if err := checkNumeric(precoNegocio, precoNegocioPrecision, precoNegocioScale); err != nil {
+		return Trade{}, fmt.Errorf("parse error preco_negocio at row %d: %w", row, err)
+	}
+
+	quantidadeStr := strings.ReplaceAll(cols.field(record, colQuantidadeNegociada), ",", "")
+	quantidadeNegociada, err := strconv.Atoi(quantidadeStr)
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error quantidade_negociada at row %d: %w", row, err)
+	}
+
+	dataHoraNegocio, err := parseHoraFechamento(dataNegocio, cols.field(record, colHoraFechamento))
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error hora_fechamento at row %d: %w", row, err)
+	}
+
+	codigoIdentificadorNegocio, err := strconv.ParseInt(cols.field(record, colCodigoIdentificadorNegocio), 10, 64)
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error codigo_identificador_negocio at row %d: %w", row, err)
+	}
+
+	tipoSessaoPregao, err := strconv.Atoi(cols.field(record, colTipoSessaoPregao))
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error tipo_sessao_pregao at row %d: %w", row, err)
+	}
+
+	comprador, err := parseParticipante(cols.field(record, colCodigoParticipanteComprador))
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error codigo_participante_comprador at row %d: %w", row, err)
+	}
+
+	vendedor, err := parseParticipante(cols.field(record, colCodigoParticipanteVendedor))
+	if err != nil {
+		return Trade{}, fmt.Errorf("parse error codigo_participante_vendedor at row %d: %w", row, err)
+	}
+
+	return Trade{
+		DataReferencia:              dataReferencia,
+		DataNegocio:                 dataNegocio,
+		CodigoInstrumento:           ticker.Code,
+		AcaoAtualizacao:             acaoAtualizacao,
+		PrecoNegocio:                precoNegocio,
+		QuantidadeNegociada:         quantidadeNegociada,
+		DataHoraNegocio:             dataHoraNegocio,
+		CodigoIdentificadorNegocio:  codigoIdentificadorNegocio,
+		TipoSessaoPregao:            tipoSessaoPregao,
+		CodigoParticipanteComprador: comprador,
+		CodigoParticipanteVendedor:  vendedor,
+		CreatedAt:                   time.Now(),
+	}, nil
+}
+
+// parseHoraFechamento combines the trade date with the HoraFechamento column,
+// published by B3 as HHMMSSmmm in São Paulo time. Leading zeros may be missing,
+// and older files carry only HHMMSS.
+func parseHoraFechamento(dataNegocio time.Time, horaStr string) (time.Time, error) {
+	if _, err := strconv.Atoi(horaStr); err != nil || len(horaStr) < 6 || len(horaStr) > 9 {
+		return time.Time{}, fmt.Errorf("invalid hora_fechamento: %s", horaStr)
+	}
+	if len(horaStr) == 6 {
+		horaStr += "000"
+	}
+	horaStr = fmt.Sprintf("%09s", horaStr)
+
+	hora, _ := strconv.Atoi(horaStr[:2])
+	minuto, _ := strconv.Atoi(horaStr[2:4])
+	segundo, _ := strconv.Atoi(horaStr[4:6])
+	milissegundo, _ := strconv.Atoi(horaStr[6:9])
+	if hora > 23 || minuto > 59 || segundo > 59 {
+		return time.Time{}, fmt.Errorf("invalid hora_fechamento: %s", horaStr)
+	}
+
+	year, month, day := dataNegocio.Date()
+	return time.Date(year, month, day, hora, minuto, segundo, milissegundo*int(time.Millisecond), calendar.SaoPaulo), nil
+}
+
+// parseParticipante parses a broker code. B3 leaves the field empty when the
+// participant is not disclosed, which is kept as 0.
+func parseParticipante(codigo string) (int, error) {
+	if codigo == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(codigo)
+}
+
+// parseInstruments converts rows of the instrument registry into instruments,
+// collecting the rows that fail to parse.
+func parseInstruments(records [][]string, offset int, cols columnMap) ([]Instrument, []rowError) {
+	instruments := make([]Instrument, 0, len(records))
+	var rowErrs []rowError
+
+	for i, record := range records {
+		row := offset + i + 1
+		instrument, err := parseInstrumentRecord(record, row, cols)
+		if err != nil {
+			rowErrs = append(rowErrs, rowError{row: row, record: record, err: err})
+			continue
 		}
 
-		trades = append(trades, trade)
+		instruments = append(instruments, instrument)
 	}
 
-	return trades, nil
+	return instruments, rowErrs
 }
 
-func parseHoraFechamento(horaStr string) (string, error) {
-	if len(horaStr) < 6 {
-		return "", fmt.Errorf("invalid hora_fechamento: %s", horaStr)
+func parseInstrumentRecord(record []string, row int, cols columnMap) (Instrument, error) {
+	if len(record) < cols.width {
+		return Instrument{}, fmt.Errorf("parse error at row %d: expected %d columns, got %d", row, cols.width, len(record))
+	}
+
+	dataReferencia, err := time.Parse("2006-01-02", cols.field(record, colInstrumentDataReferencia))
+	if err != nil {
+		return Instrument{}, fmt.Errorf("parse error data_referencia at row %d: %w", row, err)
 	}
 
-	hora := horaStr[:2]
-	minuto := horaStr[2:4]
-	segundo := horaStr[4:6]
+	codigo := strings.TrimSpace(cols.field(record, colInstrumentCodigo))
+	if codigo == "" {
+		return Instrument{}, fmt.Errorf("parse error codigo_instrumento at row %d: empty value", row)
+	}
+
+	// Only derivatives have an expiration date.
+	var dataVencimento *time.Time
+	if v := strings.TrimSpace(cols.field(record, colInstrumentDataVencimento)); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return Instrument{}, fmt.Errorf("parse error data_vencimento at row %d: %w", row, err)
+		}
+		dataVencimento = &d
+	}
 
-	return fmt.Sprintf("%s:%s:%s", hora, minuto, segundo), nil
+	return Instrument{
+		DataReferencia:    dataReferencia,
+		CodigoInstrumento: codigo,
+		Ativo:             strings.TrimSpace(cols.field(record, colInstrumentAtivo)),
+		Segmento:          strings.TrimSpace(cols.field(record, colInstrumentSegmento)),
+		Mercado:           strings.TrimSpace(cols.field(record, colInstrumentMercado)),
+		Categoria:         strings.TrimSpace(cols.field(record, colInstrumentCategoria)),
+		ISIN:              strings.TrimSpace(cols.field(record, colInstrumentISIN)),
+		DataVencimento:    dataVencimento,
+		RazaoSocial:       strings.TrimSpace(cols.field(record, colInstrumentRazaoSocial)),
+	}, nil
 }
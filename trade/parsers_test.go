@@ -2,30 +2,56 @@ package trade
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
+// testColumns maps the columns of a header in the order of the TradeIntraday layout.
+func testColumns(t *testing.T) columnMap {
+	t.Helper()
+
+	cols, err := newColumnMap(tradeIntradayHeader, tradeIntradayHeader)
+	if err != nil {
+		t.Fatalf("not expect error: %v", err)
+	}
+	return cols
+}
+
 func TestParseHoraFechamento(t *testing.T) {
+	dataNegocio := time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC)
+
 	tests := []struct {
 		name      string
 		input     string
 		want      string
 		expectErr bool
 	}{
-		{"vaild hour", "123456", "12:34:56", false},
+		{"hour with milliseconds", "123456789", "2024-08-16T12:34:56.789-03:00", false},
+		{"missing leading zero", "93000123", "2024-08-16T09:30:00.123-03:00", false},
+		{"hour without milliseconds", "123456", "2024-08-16T12:34:56-03:00", false},
+		{"just zeros", "000000", "2024-08-16T00:00:00-03:00", false},
 		{"short time", "1234", "", true},
-		{"just zeros", "000000", "00:00:00", false},
+		{"not a number", "12:34:56", "", true},
+		{"invalid minute", "126000000", "", true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseHoraFechamento(tt.input)
+			got, err := parseHoraFechamento(dataNegocio, tt.input)
 			if (err != nil) != tt.expectErr {
 				t.Errorf("expected error=%v, but=%v (%v)", tt.expectErr, err != nil, err)
 			}
-			if got != tt.want {
-				t.Errorf("expected %q, obtained %q", tt.want, got)
+			if tt.expectErr {
+				return
+			}
+			if got.Format(time.RFC3339Nano) != tt.want {
+				t.Errorf("expected %q, obtained %q", tt.want, got.Format(time.RFC3339Nano))
+			}
+			if got.Location().String() != "America/Sao_Paulo" {
+				t.Errorf("expected America/Sao_Paulo, obtained %s", got.Location())
 			}
 		})
 	}
@@ -41,8 +67,7 @@ func TestParseTrade(t *testing.T) {
 		{
 			name: "valid trade",
 			records: [][]string{
-				{"header"},
-				{"", "PETR4", "", "10,50", "1000", "123456", "", "", "2024-08-16"},
+				{"2024-08-16", "PETR4", "0", "10,50", "1000", "123456", "10", "1", "2024-08-16", "3", "72"},
 			},
 			wantErr:   false,
 			expectLen: 1,
@@ -50,32 +75,57 @@ func TestParseTrade(t *testing.T) {
 		{
 			name: "invalid datetime",
 			records: [][]string{
-				{"header"},
-				{"", "VALE3", "", "50,00", "500", "123456", "", "", "16-08-2024"},
+				{"2024-08-16", "VALE3", "0", "50,00", "500", "123456", "10", "1", "16-08-2024", "3", "72"},
 			},
 			wantErr: true,
 		},
 		{
 			name: "invalid price",
 			records: [][]string{
-				{"header"},
-				{"", "ITUB4", "", "abc", "200", "123456", "", "", "2024-08-16"},
+				{"2024-08-16", "ITUB4", "0", "abc", "200", "123456", "10", "1", "2024-08-16", "3", "72"},
 			},
 			wantErr: true,
 		},
 		{
 			name: "invalid quantity",
 			records: [][]string{
-				{"header"},
-				{"", "BBDC3", "", "20,00", "dez", "123456", "", "", "2024-08-16"},
+				{"2024-08-16", "BBDC3", "0", "20,00", "dez", "123456", "10", "1", "2024-08-16", "3", "72"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing columns",
+			records: [][]string{
+				{"2024-08-16", "PETR4", "0", "10,50", "1000", "123456", "10", "1", "2024-08-16"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid trade identifier",
+			records: [][]string{
+				{"2024-08-16", "PETR4", "0", "10,50", "1000", "123456", "abc", "1", "2024-08-16", "3", "72"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "correction",
+			records: [][]string{
+				{"2024-08-16", "PETR4", "1", "10,55", "1000", "123456", "10", "1", "2024-08-16", "3", "72"},
+			},
+			wantErr:   false,
+			expectLen: 1,
+		},
+		{
+			name: "unsupported acao_atualizacao",
+			records: [][]string{
+				{"2024-08-16", "PETR4", "3", "10,50", "1000", "123456", "10", "1", "2024-08-16", "3", "72"},
 			},
 			wantErr: true,
 		},
 		{
 			name: "invalid hour",
 			records: [][]string{
-				{"header"},
-				{"", "BBAS3", "", "30,00", "200", "1234", "", "", "2024-08-16"},
+				{"2024-08-16", "BBAS3", "0", "30,00", "200", "1234", "10", "1", "2024-08-16", "3", "72"},
 			},
 			wantErr: true,
 		},
@@ -83,10 +133,10 @@ func TestParseTrade(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			trades, err := parseTrade(tt.records)
+			trades, rowErrs := parseTrade(tt.records, 1, testColumns(t))
 
-			if (err != nil) != tt.wantErr {
-				t.Fatalf("expected error=%t, but obtained=%v", tt.wantErr, err)
+			if (len(rowErrs) > 0) != tt.wantErr {
+				t.Fatalf("expected error=%t, but obtained=%v", tt.wantErr, rowErrs)
 			}
 
 			if !tt.wantErr {
@@ -99,15 +149,189 @@ func TestParseTrade(t *testing.T) {
 					if trade.CodigoInstrumento != "PETR4" {
 						t.Errorf("expect CodigoInstrumento=PETR4, obtained=%s", trade.CodigoInstrumento)
 					}
-					if !reflect.DeepEqual(trade.HoraFechamento, "12:34:56") {
-						t.Errorf("expect HoraFechamento=12:34:56, obtained=%s", trade.HoraFechamento)
+					if trade.DataHoraNegocio.Format("2006-01-02 15:04:05") != "2024-08-16 12:34:56" {
+						t.Errorf("expect DataHoraNegocio=2024-08-16 12:34:56, obtained=%s", trade.DataHoraNegocio)
 					}
 					wantDate, _ := time.Parse("2006-01-02", "2024-08-16")
 					if !trade.DataNegocio.Equal(wantDate) {
 						t.Errorf("expect DataNegocio=%v, obtained=%v", wantDate, trade.DataNegocio)
 					}
+					if !trade.DataReferencia.Equal(wantDate) {
+						t.Errorf("expect DataReferencia=%v, obtained=%v", wantDate, trade.DataReferencia)
+					}
+					if trade.CodigoIdentificadorNegocio != 10 || trade.TipoSessaoPregao != 1 {
+						t.Errorf("expect CodigoIdentificadorNegocio=10 TipoSessaoPregao=1, obtained=%d %d", trade.CodigoIdentificadorNegocio, trade.TipoSessaoPregao)
+					}
+					if trade.CodigoParticipanteComprador != 3 || trade.CodigoParticipanteVendedor != 72 {
+						t.Errorf("expect participants 3/72, obtained=%d/%d", trade.CodigoParticipanteComprador, trade.CodigoParticipanteVendedor)
+					}
 				}
 			}
 		})
 	}
 }
+
+func TestParseTrade_EmptyParticipants(t *testing.T) {
+	records := [][]string{
+		{"2024-08-16", "PETR4", "0", "10,50", "1000", "123456", "10", "1", "2024-08-16", "", ""},
+	}
+
+	trades, rowErrs := parseTrade(records, 1, testColumns(t))
+	if len(rowErrs) > 0 {
+		t.Fatalf("not expect error: %v", rowErrs[0].err)
+	}
+	if trades[0].CodigoParticipanteComprador != 0 || trades[0].CodigoParticipanteVendedor != 0 {
+		t.Errorf("expect empty participants as 0, obtained=%d/%d", trades[0].CodigoParticipanteComprador, trades[0].CodigoParticipanteVendedor)
+	}
+}
+
+func TestParseTrade_CollectsRowErrors(t *testing.T) {
+	records := [][]string{
+		{"2024-08-16", "PETR4", "0", "10,50", "1000", "123456", "10", "1", "2024-08-16", "3", "72"},
+		{"2024-08-16", "PETR4", "0", "abc", "1000", "123456", "11", "1", "2024-08-16", "3", "72"},
+		{"2024-08-16", "PETR4", "0", "10,60", "1000", "123457", "12", "1", "2024-08-16", "3", "72"},
+	}
+
+	trades, rowErrs := parseTrade(records, 10, testColumns(t))
+
+	if len(trades) != 2 {
+		t.Errorf("expected 2 trades, obtained %d", len(trades))
+	}
+	if len(rowErrs) != 1 {
+		t.Fatalf("expected 1 row error, obtained %d", len(rowErrs))
+	}
+	if rowErrs[0].row != 12 {
+		t.Errorf("expected row 12, obtained %d", rowErrs[0].row)
+	}
+	if !reflect.DeepEqual(rowErrs[0].record, records[1]) {
+		t.Errorf("expected record %v, obtained %v", records[1], rowErrs[0].record)
+	}
+}
+
+func TestNewColumnMap(t *testing.T) {
+	t.Run("maps reordered columns by name", func(t *testing.T) {
+		header := []string{
+			"CodigoParticipanteVendedor", "CodigoParticipanteComprador", "DataNegocio", "TipoSessaoPregao",
+			"CodigoIdentificadorNegocio", "HoraFechamento", "QuantidadeNegociada", "PrecoNegocio",
+			"AcaoAtualizacao", "CodigoInstrumento", "DataReferencia",
+		}
+		cols, err := newColumnMap(tradeIntradayHeader, header)
+		if err != nil {
+			t.Fatalf("not expect error: %v", err)
+		}
+
+		records := [][]string{
+			{"72", "3", "2024-08-16", "1", "10", "123456", "1000", "10,50", "0", "PETR4", "2024-08-16"},
+		}
+		trades, rowErrs := parseTrade(records, 1, cols)
+		if len(rowErrs) > 0 {
+			t.Fatalf("not expect error: %v", rowErrs[0].err)
+		}
+		if trades[0].CodigoInstrumento != "PETR4" || !trades[0].PrecoNegocio.Equal(decimal.RequireFromString("10.5")) || trades[0].CodigoParticipanteVendedor != 72 {
+			t.Errorf("unexpected trade %+v", trades[0])
+		}
+	})
+
+	t.Run("ignores case, spaces and extra columns", func(t *testing.T) {
+		header := append([]string{" RptDt "}, tradeIntradayHeader...)
+		header[1] = "datareferencia"
+
+		cols, err := newColumnMap(tradeIntradayHeader, header)
+		if err != nil {
+			t.Fatalf("not expect error: %v", err)
+		}
+		if cols.index[colDataReferencia] != 1 || cols.width != len(header) {
+			t.Errorf("unexpected column map %+v", cols)
+		}
+	})
+
+	t.Run("fails on missing or renamed columns", func(t *testing.T) {
+		header := append([]string{}, tradeIntradayHeader...)
+		header[colPrecoNegocio] = "Preco"
+		header = header[:colCodigoParticipanteVendedor]
+
+		_, err := newColumnMap(tradeIntradayHeader, header)
+		if err == nil || err.Error() != "missing columns in header: PrecoNegocio, CodigoParticipanteVendedor" {
+			t.Errorf("expected missing columns error, obtained %v", err)
+		}
+	})
+
+	t.Run("fails on duplicated columns", func(t *testing.T) {
+		header := append([]string{"DataNegocio"}, tradeIntradayHeader...)
+
+		_, err := newColumnMap(tradeIntradayHeader, header)
+		if err == nil || err.Error() != "duplicated column DataNegocio in header" {
+			t.Errorf("expected duplicated column error, obtained %v", err)
+		}
+	})
+}
+
+func TestParseTrade_ExactPrice(t *testing.T) {
+	records := [][]string{
+		{"2024-08-16", "PETR4", "0", "0,1", "1000", "123456", "10", "1", "2024-08-16", "3", "72"},
+		{"2024-08-16", "PETR4", "0", "37,05", "1000", "123456", "11", "1", "2024-08-16", "3", "72"},
+	}
+
+	trades, rowErrs := parseTrade(records, 1, testColumns(t))
+	if len(rowErrs) > 0 {
+		t.Fatalf("not expect error: %v", rowErrs[0].err)
+	}
+
+	sum := trades[0].PrecoNegocio.Add(trades[0].PrecoNegocio).Add(trades[0].PrecoNegocio)
+	if !sum.Equal(decimal.RequireFromString("0.3")) {
+		t.Errorf("expected 0.1 * 3 = 0.3 exactly, obtained %s", sum)
+	}
+	if trades[1].PrecoNegocio.String() != "37.05" {
+		t.Errorf("expected 37.05, obtained %s", trades[1].PrecoNegocio)
+	}
+}
+
+func TestParseTrade_PricePrecision(t *testing.T) {
+	tests := []struct {
+		name    string
+		price   string
+		wantErr string
+	}{
+		{"option with cents", "0,01", ""},
+		{"DI1 rate", "10,875", ""},
+		{"mini index", "130125", ""},
+		{"largest price", "999999999999,99999999", ""},
+		{"too many decimal places", "1,123456789", "more than 8 decimal places"},
+		{"too many integer digits", "1000000000000", "more than 12 integer digits"},
+		{"trailing zeros beyond the scale", "10,5000000000", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := [][]string{
+				{"2024-08-16", "DI1F25", "0", tt.price, "5", "123456", "10", "1", "2024-08-16", "3", "72"},
+			}
+
+			_, rowErrs := parseTrade(records, 1, testColumns(t))
+			if tt.wantErr == "" {
+				if len(rowErrs) > 0 {
+					t.Fatalf("not expect error: %v", rowErrs[0].err)
+				}
+				return
+			}
+			if len(rowErrs) != 1 || !strings.Contains(rowErrs[0].err.Error(), tt.wantErr) {
+				t.Fatalf("expected error %q, obtained %v", tt.wantErr, rowErrs)
+			}
+		})
+	}
+}
+
+func TestParseTrade_Ticker(t *testing.T) {
+	records := [][]string{
+		{"2024-08-16", " petr4f ", "0", "37,05", "10", "123456", "10", "1", "2024-08-16", "3", "72"},
+		{"2024-08-16", "PETR-4", "0", "37,05", "10", "123456", "11", "1", "2024-08-16", "3", "72"},
+	}
+
+	trades, rowErrs := parseTrade(records, 1, testColumns(t))
+	if len(trades) != 1 || trades[0].CodigoInstrumento != "PETR4F" {
+		t.Fatalf("expected the normalized ticker PETR4F, obtained %+v", trades)
+	}
+	if len(rowErrs) != 1 || !strings.Contains(rowErrs[0].err.Error(), "parse error codigo_instrumento at row 3") {
+		t.Fatalf("expected a codigo_instrumento error at row 3, obtained %v", rowErrs)
+	}
+}
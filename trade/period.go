@@ -0,0 +1,59 @@
+package trade
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gurodrigues-dev/b3-reader/internal/calendar"
+)
+
+const (
+	// defaultSessions is how many B3 trading sessions the aggregation covers
+	// when no start date is given.
+	defaultSessions = 7
+	// maxPeriodDays is the longest period, in calendar days, that can be aggregated.
+	maxPeriodDays = 366
+)
+
+// resolvePeriod fills in the dates missing from an aggregation request and
+// validates the period. Dates are calendar days in São Paulo, represented at
+// midnight UTC like the dates of the query string. Without an end date the
+// period ends on the last session up to today; without a start date it covers
+// the last defaultSessions sessions up to its end.
+func resolvePeriod(now time.Time, startDate, endDate *time.Time) (time.Time, time.Time, error) {
+	var start, end time.Time
+	if startDate != nil {
+		start = dateOf(*startDate)
+	}
+
+	switch {
+	case endDate != nil:
+		end = dateOf(*endDate)
+	default:
+		end = calendar.LastSession(now.In(calendar.SaoPaulo))
+		// A start date past the last session still yields a valid, empty period.
+		if startDate != nil && start.After(end) {
+			end = start
+		}
+	}
+
+	if startDate == nil {
+		start = calendar.AddSessions(calendar.LastSession(end), -(defaultSessions - 1))
+	}
+
+	if start.After(end) {
+		return time.Time{}, time.Time{}, validationError(fmt.Errorf("data_inicio %s is after data_fim %s",
+			start.Format("2006-01-02"), end.Format("2006-01-02")))
+	}
+	if end.Sub(start) > maxPeriodDays*24*time.Hour {
+		return time.Time{}, time.Time{}, validationError(fmt.Errorf("period from %s to %s is longer than %d days",
+			start.Format("2006-01-02"), end.Format("2006-01-02"), maxPeriodDays))
+	}
+
+	return start, end, nil
+}
+
+// dateOf returns the calendar day of t at midnight UTC.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
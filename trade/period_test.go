@@ -0,0 +1,61 @@
+package trade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gurodrigues-dev/b3-reader/internal/calendar"
+)
+
+func TestResolvePeriod(t *testing.T) {
+	date := func(s string) *time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+	// A Saturday: the default period ends on the Friday before.
+	now := time.Date(2024, 8, 17, 10, 0, 0, 0, calendar.SaoPaulo)
+
+	tests := []struct {
+		name      string
+		start     *time.Time
+		end       *time.Time
+		wantStart string
+		wantEnd   string
+		wantErr   string
+	}{
+		{name: "defaults", wantStart: "2024-08-08", wantEnd: "2024-08-16"},
+		{name: "start only", start: date("2024-08-01"), wantStart: "2024-08-01", wantEnd: "2024-08-16"},
+		{name: "start after the last session", start: date("2024-08-17"), wantStart: "2024-08-17", wantEnd: "2024-08-17"},
+		{name: "end only", end: date("2024-08-07"), wantStart: "2024-07-30", wantEnd: "2024-08-07"},
+		{name: "end only skips carnaval", end: date("2025-03-07"), wantStart: "2025-02-25", wantEnd: "2025-03-07"},
+		{name: "both", start: date("2024-08-05"), end: date("2024-08-09"), wantStart: "2024-08-05", wantEnd: "2024-08-09"},
+		{name: "single day", start: date("2024-08-05"), end: date("2024-08-05"), wantStart: "2024-08-05", wantEnd: "2024-08-05"},
+		{name: "start after end", start: date("2024-08-09"), end: date("2024-08-05"), wantErr: "data_inicio 2024-08-09 is after data_fim 2024-08-05"},
+		{name: "longest period", start: date("2023-08-16"), end: date("2024-08-16"), wantStart: "2023-08-16", wantEnd: "2024-08-16"},
+		{name: "too long", start: date("2023-08-15"), end: date("2024-08-16"), wantErr: "period from 2023-08-15 to 2024-08-16 is longer than 366 days"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := resolvePeriod(now, tt.start, tt.end)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, obtained %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expect error: %v", err)
+			}
+			if got := start.Format("2006-01-02"); got != tt.wantStart {
+				t.Errorf("start = %s, want %s", got, tt.wantStart)
+			}
+			if got := end.Format("2006-01-02"); got != tt.wantEnd {
+				t.Errorf("end = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package trade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gurodrigues-dev/b3-reader/internal/batcher"
+	"github.com/gurodrigues-dev/b3-reader/internal/reader"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	batchSize = 5000
+	// pipelineDepth is how many batches the parse stage may get ahead of the write stage.
+	pipelineDepth = 2
+)
+
+// writeBatch is a unit of work handed from the parse stage to the write stage.
+type writeBatch struct {
+	trades []Trade
+	// corrections and cancellations are applied after trades, in that order,
+	// since B3 may publish a trade and its updates in the same file.
+	corrections   []Trade
+	cancellations []Trade
+	instruments   []Instrument
+	bars          []DailyBar
+	rejected      []RejectedTrade
+}
+
+// records is the number of parsed records in the batch.
+func (b writeBatch) records() int {
+	return len(b.trades) + len(b.corrections) + len(b.cancellations) + len(b.instruments) + len(b.bars)
+}
+
+// hasExtra reports whether the batch holds records other than trades, which
+// flush the pending trades so the write order follows the file.
+func (b writeBatch) hasExtra() bool {
+	return len(b.corrections) > 0 || len(b.cancellations) > 0 || len(b.instruments) > 0 || len(b.bars) > 0 || len(b.rejected) > 0
+}
+
+// readFile streams a file through two concurrent stages: the parse stage turns
+// chunks into batches of batchSize records, according to the layout of the file,
+// while the write stage stores them
+// through w, so parsing and database I/O overlap with bounded memory. With
+// replace, stored trades are replaced by the ones read, for files loaded again.
+func (s *Service) readFile(ctx context.Context, w Writer, file reader.File, replace bool) (IngestSummary, error) {
+	g, ctx := errgroup.WithContext(ctx)
+	batches := make(chan writeBatch, pipelineDepth)
+
+	var parsed, written IngestSummary
+	g.Go(func() error {
+		defer close(batches)
+		return s.parseStage(ctx, file, batches, &parsed)
+	})
+	g.Go(func() error {
+		return s.writeStage(ctx, w, batches, replace, &written)
+	})
+
+	err := g.Wait()
+	parsed.add(written)
+	parsed.Files = 1
+
+	return parsed, err
+}
+
+func (s *Service) parseStage(ctx context.Context, file reader.File, batches chan<- writeBatch, summary *IngestSummary) error {
+	// Fixed-width layouts must be known before reading, as their files have no header.
+	if l := s.layouts.byName(file.Name); l != nil {
+		file.Format = l.format
+	}
+	chunksChan, errChan := s.csvreader.Read(ctx, file)
+
+	var parse chunkParser
+	var pending []Trade
+	for {
+		select {
+		case chunk, ok := <-chunksChan:
+			if !ok {
+				return emit(ctx, batches, pending, writeBatch{})
+			}
+
+			if parse == nil {
+				var err error
+				if parse, err = s.newParser(file, chunk.Header); err != nil {
+					return err
+				}
+			}
+
+			batch, err := s.parseChunk(ctx, file, chunk, parse, summary)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, batch.trades...)
+
+			if batch.hasExtra() {
+				batch.trades = nil
+				if err := emit(ctx, batches, pending, batch); err != nil {
+					return err
+				}
+				pending = nil
+				continue
+			}
+
+			for len(pending) >= batchSize {
+				if err := send(ctx, batches, writeBatch{trades: pending[:batchSize]}); err != nil {
+					return err
+				}
+				pending = pending[batchSize:]
+			}
+
+		case err, ok := <-errChan:
+			if ok {
+				return unavailableError(fmt.Errorf("file read error: %w", err))
+			}
+
+		case <-ctx.Done():
+			s.logger.Info("context canceled")
+			return ctx.Err()
+		}
+	}
+}
+
+// newParser selects the layout of a file by its name and header.
+func (s *Service) newParser(file reader.File, header []string) (chunkParser, error) {
+	l, err := s.layouts.detect(file.Name, header)
+	if err != nil {
+		return nil, validationError(err)
+	}
+	s.logger.Info("detected file layout", zap.String("file", file.Name), zap.String("layout", l.name))
+
+	parse, err := l.newParser(header)
+	if err != nil {
+		return nil, validationError(fmt.Errorf("invalid header in file %s: %w", file.Path, err))
+	}
+
+	return parse, nil
+}
+
+// parseChunk parses the rows of a chunk, returning a batch with the records to
+// be written and the quarantined rows.
+func (s *Service) parseChunk(ctx context.Context, file reader.File, chunk reader.Chunk, parse chunkParser, summary *IngestSummary) (writeBatch, error) {
+	s.logger.Debug("making parse records", zap.String("file", chunk.File), zap.Int("offset", chunk.Offset))
+	batch, rowErrs := parse(chunk.Rows, chunk.Offset)
+	if chunk.Invalid != nil {
+		rowErrs = append(rowErrs, rowError{
+			row:    chunk.Offset + len(chunk.Rows) + 1,
+			record: []string{chunk.Invalid.Line},
+			err:    chunk.Invalid.Err,
+		})
+	}
+	// Rows skipped by the parser, such as the header and trailer records of
+	// fixed-width files, are not data rows.
+	summary.Rows += int64(batch.records() + len(rowErrs))
+	for _, t := range batch.trades {
+		summary.addSession(DatasetTrades, t.DataNegocio)
+	}
+	for _, b := range batch.bars {
+		summary.addSession(DatasetDailyBars, b.DataPregao)
+	}
+
+	rejected, err := s.rejectRows(file, rowErrs, summary)
+	if err != nil {
+		// The rows that exceeded the limit are kept to tell why the file failed.
+		if qerr := s.quarantine(ctx, rejected); qerr != nil {
+			return writeBatch{}, errors.Join(err, qerr)
+		}
+		return writeBatch{}, err
+	}
+	batch.rejected = rejected
+
+	return batch, nil
+}
+
+func (s *Service) writeStage(ctx context.Context, w Writer, batches <-chan writeBatch, replace bool, summary *IngestSummary) error {
+	save := w.SaveBatch
+	if replace {
+		save = w.ReplaceBatch
+	}
+
+	idx := 0
+	for batch := range batches {
+		if len(batch.trades) > 0 {
+			idx++
+			inserted, err := save(ctx, batch.trades)
+			if err != nil {
+				return unavailableError(fmt.Errorf("database save batch error %d: %w", idx, err))
+			}
+			summary.Inserted += inserted
+			summary.Duplicated += int64(len(batch.trades)) - inserted
+		}
+
+		if len(batch.corrections) > 0 {
+			corrected, err := w.CorrectTrades(ctx, batch.corrections)
+			if err != nil {
+				return unavailableError(fmt.Errorf("database correct trades error: %w", err))
+			}
+			summary.Corrected += corrected
+		}
+
+		if len(batch.cancellations) > 0 {
+			cancelled, err := w.CancelTrades(ctx, batch.cancellations)
+			if err != nil {
+				return unavailableError(fmt.Errorf("database cancel trades error: %w", err))
+			}
+			summary.Cancelled += cancelled
+		}
+
+		if len(batch.instruments) > 0 {
+			written, err := w.SaveInstruments(ctx, batch.instruments)
+			if err != nil {
+				return unavailableError(fmt.Errorf("database save instruments error: %w", err))
+			}
+			summary.Inserted += written
+		}
+
+		if len(batch.bars) > 0 {
+			written, err := w.SaveDailyBars(ctx, batch.bars)
+			if err != nil {
+				return unavailableError(fmt.Errorf("database save daily bars error: %w", err))
+			}
+			summary.Inserted += written
+		}
+
+		if err := s.quarantine(ctx, batch.rejected); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// quarantine stores rejected rows through the repository rather than the file
+// transaction, so they are kept when the file is rolled back.
+func (s *Service) quarantine(ctx context.Context, rejected []RejectedTrade) error {
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	if _, err := s.repository.SaveRejected(ctx, rejected); err != nil {
+		return unavailableError(fmt.Errorf("database save rejected rows error: %w", err))
+	}
+
+	return nil
+}
+
+// emit sends the pending trades in batches of batchSize, attaching extra to the last one.
+func emit(ctx context.Context, batches chan<- writeBatch, pending []Trade, extra writeBatch) error {
+	chunks, err := batcher.Batch(pending, batchSize)
+	if err != nil {
+		return unavailableError(fmt.Errorf("batch error: %w", err))
+	}
+
+	for idx, trades := range chunks {
+		batch := writeBatch{trades: trades}
+		if idx == len(chunks)-1 {
+			batch.corrections, batch.cancellations = extra.corrections, extra.cancellations
+			batch.instruments, batch.bars = extra.instruments, extra.bars
+			batch.rejected = extra.rejected
+		}
+		if err := send(ctx, batches, batch); err != nil {
+			return err
+		}
+	}
+
+	if len(chunks) == 0 && extra.hasExtra() {
+		return send(ctx, batches, extra)
+	}
+
+	return nil
+}
+
+func send(ctx context.Context, batches chan<- writeBatch, batch writeBatch) error {
+	select {
+	case batches <- batch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rejectRows applies the error policy to the rows of a chunk that failed to
+// parse, returning the rows to be quarantined. They are also returned along
+// with the error when the rows exceed the limit of rejected rows.
+func (s *Service) rejectRows(file reader.File, rowErrs []rowError, summary *IngestSummary) ([]RejectedTrade, error) {
+	if len(rowErrs) == 0 {
+		return nil, nil
+	}
+
+	if s.errorPolicy == ErrorPolicyFailFast {
+		return nil, validationError(fmt.Errorf("parse error in file %s: %w", file.Path, rowErrs[0].err))
+	}
+
+	summary.Rejected += int64(len(rowErrs))
+	var err error
+	if s.maxRejected > 0 && summary.Rejected > int64(s.maxRejected) {
+		err = validationError(fmt.Errorf("rejected rows in file %s exceed the limit of %d: %w", file.Path, s.maxRejected, rowErrs[0].err))
+	}
+
+	s.logger.Warn("rejecting invalid rows",
+		zap.String("file", file.Name),
+		zap.Int("rows", len(rowErrs)),
+		zap.String("policy", string(s.errorPolicy)),
+	)
+	if s.errorPolicy != ErrorPolicyQuarantine {
+		return nil, err
+	}
+
+	now := time.Now()
+	rejected := make([]RejectedTrade, len(rowErrs))
+	for i, re := range rowErrs {
+		rejected[i] = RejectedTrade{
+			FileName:   file.Name,
+			LineNumber: re.row,
+			// Fixed-width files are Latin-1 encoded, which is not valid text for the database.
+			RawLine:   strings.ToValidUTF8(strings.Join(re.record, string(file.Sep)), "\uFFFD"),
+			Reason:    re.err.Error(),
+			CreatedAt: now,
+		}
+	}
+
+	return rejected, err
+}
+
+// splitUpdates separates the correction and cancellation records from the trades to be saved.
+func splitUpdates(records []Trade) ([]Trade, []Trade, []Trade) {
+	trades := records[:0]
+	var corrections, cancellations []Trade
+
+	for _, t := range records {
+		switch {
+		case t.IsCorrection():
+			corrections = append(corrections, t)
+		case t.IsCancellation():
+			cancellations = append(cancellations, t)
+		default:
+			trades = append(trades, t)
+		}
+	}
+
+	return trades, corrections, cancellations
+}
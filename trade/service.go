@@ -2,103 +2,544 @@ package trade
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
-	"github.com/gurodrigues-dev/b3-reader/internal/batcher"
+	"github.com/gurodrigues-dev/b3-reader/internal/calendar"
 	"github.com/gurodrigues-dev/b3-reader/internal/reader"
 	"go.uber.org/zap"
 )
 
-const (
-	batchSize = 5000
-)
-
 type Service struct {
-	repository Repository
-	csvreader  reader.Reader
-	logger     *zap.Logger
+	repository  Repository
+	csvreader   reader.Reader
+	logger      *zap.Logger
+	errorPolicy ErrorPolicy
+	maxRejected int
+	workers     int
+	layouts     *registry
+}
+
+// Option configures optional behaviour of the Service.
+type Option func(*Service)
+
+// WithErrorPolicy sets how rows that fail to parse are handled. Under the skip and
+// quarantine policies a file fails once more than maxRejected rows are rejected;
+// zero or less means no limit.
+func WithErrorPolicy(policy ErrorPolicy, maxRejected int) Option {
+	return func(s *Service) {
+		s.errorPolicy = policy
+		s.maxRejected = maxRejected
+	}
 }
 
-func NewService(r Repository, csv reader.Reader, l *zap.Logger) *Service {
-	return &Service{
-		repository: r,
-		csvreader:  csv,
-		logger:     l,
+// WithWorkers sets how many files are ingested concurrently, each one on its own
+// database connection.
+func WithWorkers(n int) Option {
+	return func(s *Service) {
+		s.workers = max(n, 1)
 	}
 }
 
-func (s *Service) IngestFiles(ctx context.Context, filePath string) error {
-	s.logger.Info("ingesting files...")
-	recordsChan, errChan := s.csvreader.Read(ctx)
+func NewService(r Repository, csv reader.Reader, l *zap.Logger, opts ...Option) *Service {
+	s := &Service{
+		repository:  r,
+		csvreader:   csv,
+		logger:      l,
+		errorPolicy: ErrorPolicyFailFast,
+		workers:     1,
+		layouts:     defaultRegistry(),
+	}
 
-	for {
-		select {
-		case records, ok := <-recordsChan:
-			if !ok {
-				return nil
-			}
-			if err := s.processRecords(ctx, filePath, records); err != nil {
-				return err
-			}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *Service) IngestFiles(ctx context.Context, filePath string) (*IngestSummary, error) {
+	s.logger.Info("ingesting files...", zap.String("path", filePath), zap.Int("workers", s.workers))
+	files, err := s.csvreader.List(ctx)
+	if err != nil {
+		return nil, unavailableError(fmt.Errorf("file read error: %w", err))
+	}
+
+	workCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]fileResult, len(files))
+	jobs := make(chan int)
 
-		case err, ok := <-errChan:
-			if ok {
-				return fmt.Errorf("file read error: %w", err)
+	var wg sync.WaitGroup
+	for range min(s.workers, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx] = s.processFile(workCtx, files[idx])
+				if results[idx].err != nil {
+					cancel()
+				}
 			}
+		}()
+	}
+
+dispatch:
+	for idx := range files {
+		select {
+		case jobs <- idx:
+		case <-workCtx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Results are reported in the order the files were listed. Files interrupted
+	// only because another one failed are left out of the error.
+	summary := &IngestSummary{}
+	var errs []error
+	for _, res := range results {
+		summary.add(res.summary)
+		if res.err == nil || (ctx.Err() == nil && errors.Is(res.err, context.Canceled)) {
+			continue
+		}
+		errs = append(errs, res.err)
+	}
+	if ctx.Err() == nil {
+		missing, err := s.missingSessions(ctx, summary.sessions)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		summary.MissingSessions = missing
+	}
+	summary.sessions = nil
+	for _, m := range summary.MissingSessions {
+		s.logger.Warn("missing trading session",
+			zap.String("dataset", string(m.Dataset)),
+			zap.String("date", m.Date.Format("2006-01-02")),
+		)
+	}
+
+	if len(errs) == 0 && ctx.Err() != nil {
+		s.logger.Info("context canceled")
+		return summary, ctx.Err()
+	}
+
+	return summary, errors.Join(errs...)
+}
+
+// maxBatchTickers is the largest number of tickers aggregated in one request.
+const maxBatchTickers = 200
+
+func (s *Service) GetAggregatedDataBatch(ctx context.Context, tickers []string, startDate, endDate *time.Time) (*AggregatedBatch, error) {
+	if len(tickers) == 0 {
+		return nil, validationError(errors.New("tickers are required"))
+	}
+	if len(tickers) > maxBatchTickers {
+		return nil, validationError(fmt.Errorf("too many tickers: %d, the limit is %d", len(tickers), maxBatchTickers))
+	}
+
+	// Tickers are normalized before removing duplicates, so petr4 and PETR4 are queried once.
+	codes := make([]string, 0, len(tickers))
+	seen := make(map[string]bool, len(tickers))
+	for _, raw := range tickers {
+		t, err := ParseTicker(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[t.Code] {
+			seen[t.Code] = true
+			codes = append(codes, t.Code)
+		}
+	}
+
+	start, end, err := resolvePeriod(time.Now(), startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.repository.GetAggregatedDataBatch(ctx, codes, start, end)
+	if err != nil {
+		return nil, unavailableError(fmt.Errorf("fetching batch aggregated data error: %w", err))
+	}
+
+	byTicker := make(map[string]AggregatedData, len(data))
+	for _, d := range data {
+		byTicker[d.Ticker] = d
+	}
 
-		case <-ctx.Done():
-			s.logger.Info("context canceled")
-			return ctx.Err()
+	batch := &AggregatedBatch{
+		StartDate: start,
+		EndDate:   end,
+		Data:      make([]AggregatedData, 0, len(data)),
+		NotFound:  []string{},
+	}
+	for _, code := range codes {
+		d, ok := byTicker[code]
+		if !ok {
+			batch.NotFound = append(batch.NotFound, code)
+			continue
 		}
+		d.StartDate, d.EndDate = start, end
+		batch.Data = append(batch.Data, d)
+	}
+
+	return batch, nil
+}
+
+func (s *Service) GetDailyStats(ctx context.Context, ticker string, startDate, endDate *time.Time) (*DailySeries, error) {
+	t, err := ParseTicker(ticker)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := resolvePeriod(time.Now(), startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.repository.GetDailyStats(ctx, t.Code, start, end)
+	if err != nil {
+		return nil, unavailableError(fmt.Errorf("fetching daily stats error: %w", err))
+	}
+	if len(sessions) == 0 {
+		return nil, &NotFoundError{Ticker: t.Code, StartDate: start, EndDate: end}
+	}
+
+	return &DailySeries{
+		Ticker:    t.Code,
+		StartDate: start,
+		EndDate:   end,
+		Sessions:  sessions,
+	}, nil
+}
+
+func (s *Service) GetCandles(ctx context.Context, ticker string, date *time.Time, interval CandleInterval) (*CandleSeries, error) {
+	t, err := ParseTicker(ticker)
+	if err != nil {
+		return nil, err
+	}
+
+	interval, err = ParseCandleInterval(string(interval))
+	if err != nil {
+		return nil, err
+	}
+
+	day := calendar.LastSession(time.Now().In(calendar.SaoPaulo))
+	if date != nil {
+		day = dateOf(*date)
+	}
+
+	candles, err := s.repository.GetCandles(ctx, t.Code, day, interval.Duration())
+	if err != nil {
+		return nil, unavailableError(fmt.Errorf("fetching candles error: %w", err))
+	}
+	if len(candles) == 0 {
+		return nil, &NotFoundError{Ticker: t.Code, StartDate: day, EndDate: day}
+	}
+	for i := range candles {
+		candles[i].Time = candles[i].Time.In(calendar.SaoPaulo)
 	}
+
+	return &CandleSeries{
+		Ticker:   t.Code,
+		Date:     day,
+		Interval: interval,
+		Candles:  candles,
+	}, nil
 }
 
-func (s *Service) GetAggregatedData(ctx context.Context, ticker string, startDate *time.Time) (*AggregatedData, error) {
-	if ticker == "" {
-		return nil, fmt.Errorf("ticker is required")
+func (s *Service) GetAveragePrices(ctx context.Context, ticker string, startDate, endDate *time.Time, interval CandleInterval) (*AveragePrices, error) {
+	t, err := ParseTicker(ticker)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unlike the candles, windows are only calculated when asked for.
+	if interval != "" {
+		if interval, err = ParseCandleInterval(string(interval)); err != nil {
+			return nil, err
+		}
 	}
 
-	if startDate == nil {
-		defaultStartDate := time.Now().AddDate(0, 0, -7)
-		startDate = &defaultStartDate
+	start, end, err := resolvePeriod(time.Now(), startDate, endDate)
+	if err != nil {
+		return nil, err
 	}
 
-	maxRangeValue, maxDailyVolume, err := s.repository.GetAggregatedData(ctx, ticker, *startDate)
+	sessions, err := s.repository.GetSessionAverages(ctx, t.Code, start, end)
 	if err != nil {
-		return nil, fmt.Errorf("fetching aggregated data error: %w", err)
+		return nil, unavailableError(fmt.Errorf("fetching session averages error: %w", err))
+	}
+	if len(sessions) == 0 {
+		return nil, &NotFoundError{Ticker: t.Code, StartDate: start, EndDate: end}
+	}
+
+	if interval != "" {
+		windows, err := s.repository.GetWindowAverages(ctx, t.Code, start, end, interval.Duration())
+		if err != nil {
+			return nil, unavailableError(fmt.Errorf("fetching window averages error: %w", err))
+		}
+
+		bySession := make(map[time.Time][]WindowAverages, len(sessions))
+		for _, w := range windows {
+			w.Time = w.Time.In(calendar.SaoPaulo)
+			day := dateOf(w.Time)
+			bySession[day] = append(bySession[day], w)
+		}
+		for i := range sessions {
+			sessions[i].Windows = bySession[sessions[i].Date]
+		}
 	}
 
-	return &AggregatedData{
-		Ticker:         ticker,
-		MaxDailyVolume: maxDailyVolume,
-		MaxRangeValue:  maxRangeValue,
+	return &AveragePrices{
+		Ticker:    t.Code,
+		StartDate: start,
+		EndDate:   end,
+		Interval:  interval,
+		Sessions:  sessions,
 	}, nil
 }
 
-func (s *Service) processRecords(ctx context.Context, filePath string, records [][]string) error {
-	if len(records) > 0 {
-		records = records[1:]
+// missingSessions checks each dataset read against the data already stored,
+// listing the B3 sessions without data from the last date stored before the
+// first date read up to the last one. Gaps left by previous runs are found as
+// well, since the check does not depend on which files were read.
+func (s *Service) missingSessions(ctx context.Context, sessions map[Dataset]map[time.Time]struct{}) ([]MissingSession, error) {
+	var missing []MissingSession
+	for _, dataset := range []Dataset{DatasetTrades, DatasetDailyBars} {
+		days := sessions[dataset]
+		if len(days) == 0 {
+			continue
+		}
+
+		var first, last time.Time
+		for day := range days {
+			if first.IsZero() || day.Before(first) {
+				first = day
+			}
+			if day.After(last) {
+				last = day
+			}
+		}
+
+		stored, err := s.repository.GetStoredSessions(ctx, dataset, first, last)
+		if err != nil {
+			return missing, unavailableError(fmt.Errorf("fetching stored sessions error %s: %w", dataset, err))
+		}
+		if len(stored) == 0 {
+			continue
+		}
+
+		found := make(map[time.Time]struct{}, len(stored))
+		for _, day := range stored {
+			found[day] = struct{}{}
+		}
+		for _, session := range calendar.Sessions(stored[0], last) {
+			if _, ok := found[session]; !ok {
+				missing = append(missing, MissingSession{Dataset: dataset, Date: session})
+			}
+		}
 	}
 
-	s.logger.Info("making parse records")
-	trades, err := parseTrade(records)
+	return missing, nil
+}
+
+// maxFileAttempts bounds how many times a file is loaded when its transaction
+// is aborted by a conflict with another file, such as a deadlock.
+const maxFileAttempts = 3
+
+type fileResult struct {
+	summary IngestSummary
+	err     error
+}
+
+// processFile ingests a file unless the ledger shows it was already loaded.
+func (s *Service) processFile(ctx context.Context, file reader.File) fileResult {
+	in, err := s.shouldIngest(ctx, file)
+	if err != nil {
+		return fileResult{err: err}
+	}
+	if in == nil {
+		return fileResult{summary: IngestSummary{Skipped: 1}}
+	}
+
+	summary, err := s.ingestFile(ctx, file, in)
+	for attempt := 1; errors.Is(err, ErrConflict) && attempt < maxFileAttempts && ctx.Err() == nil; attempt++ {
+		// The transaction was rolled back, so the file is loaded again from the start.
+		s.logger.Warn("retrying file after a write conflict", zap.String("file", file.Name), zap.Error(err))
+		summary, err = s.ingestFile(ctx, file, in)
+	}
+	return fileResult{summary: summary, err: err}
+}
+
+// ingestion tells how a file is loaded.
+type ingestion struct {
+	checksum string
+	// replace is set for a file with a ledger entry of its own, whose trades
+	// replace the ones stored by a previous version of the file.
+	replace bool
+}
+
+// shouldIngest consults the ingestion ledger to decide whether a file must be
+// loaded, returning nil when it is skipped. A file loaded with the same size
+// and modification time is skipped without reading it; otherwise its checksum
+// is compared with the file of the same name and with files loaded under other
+// names, such as when FILE_PATH points to another folder. A file skipped for
+// matching another name is recorded as skipped, so it is not hashed again.
+func (s *Service) shouldIngest(ctx context.Context, file reader.File) (*ingestion, error) {
+	entry, err := s.repository.GetIngestedFile(ctx, file.Name)
 	if err != nil {
-		return fmt.Errorf("parse error in file %s: %w", filePath, err)
+		return nil, unavailableError(fmt.Errorf("fetching ingested file error %s: %w", file.Name, err))
+	}
+	if entry != nil && (entry.Status == FileStatusDone || entry.Status == FileStatusSkipped) && sameFile(entry, file) {
+		s.logger.Info("skipping file already ingested", zap.String("file", file.Name))
+		return nil, nil
 	}
 
-	s.logger.Info("creating batches")
-	batches, err := batcher.Batch(trades, batchSize)
+	checksum, err := s.csvreader.Checksum(ctx, file)
 	if err != nil {
-		return fmt.Errorf("batch error: %w", err)
+		return nil, unavailableError(fmt.Errorf("checksum file error %s: %w", file.Name, err))
 	}
 
-	s.logger.Info("inserting batch in db")
-	for idx, batch := range batches {
-		if _, err := s.repository.SaveBatch(ctx, batch); err != nil {
-			return fmt.Errorf("database save batch error %d: %w", idx+1, err)
+	switch {
+	case entry == nil || entry.Status == FileStatusSkipped:
+		loaded, err := s.repository.GetIngestedFileByChecksum(ctx, checksum)
+		if err != nil {
+			return nil, unavailableError(fmt.Errorf("fetching ingested file error %s: %w", file.Name, err))
+		}
+		if loaded != nil {
+			s.logger.Info("skipping file already ingested under another name",
+				zap.String("file", file.Name), zap.String("ingested_as", loaded.FileName))
+			return nil, s.skipFile(ctx, file, checksum)
 		}
+		// The file was never loaded under its name.
+		return &ingestion{checksum: checksum}, nil
+	case entry.Status == FileStatusDone && entry.Checksum == checksum:
+		s.logger.Info("skipping file already ingested", zap.String("file", file.Name))
+		return nil, nil
+	case entry.Status == FileStatusDone:
+		s.logger.Warn("file changed since last ingestion", zap.String("file", file.Name))
+	default:
+		s.logger.Info("retrying file", zap.String("file", file.Name), zap.String("status", entry.Status))
+	}
+
+	return &ingestion{checksum: checksum, replace: true}, nil
+}
+
+// skipFile records a file with the content of a file ingested under another
+// name in the ledger, with status skipped and no rows.
+func (s *Service) skipFile(ctx context.Context, file reader.File, checksum string) error {
+	modTime := file.ModTime
+	now := time.Now()
+	entry := &IngestedFile{
+		FileName:    file.Name,
+		FileSize:    file.Size,
+		FileModTime: &modTime,
+		Checksum:    checksum,
+		Status:      FileStatusSkipped,
+		StartedAt:   now,
+		FinishedAt:  &now,
+	}
+	if err := s.repository.StartIngestedFile(ctx, entry); err != nil {
+		return unavailableError(fmt.Errorf("register ingested file error %s: %w", file.Name, err))
+	}
+	if err := s.repository.FinishIngestedFile(ctx, entry); err != nil {
+		return unavailableError(fmt.Errorf("update ingested file error %s: %w", file.Name, err))
 	}
 
 	return nil
 }
+
+// sameFile reports whether file has the size and modification time recorded in
+// its ledger entry. The ledger keeps times with microseconds.
+func sameFile(entry *IngestedFile, file reader.File) bool {
+	return entry.FileModTime != nil &&
+		entry.FileSize == file.Size &&
+		entry.FileModTime.Equal(file.ModTime.Truncate(time.Microsecond))
+}
+
+// ingestFile loads a single file, keeping its ledger entry up to date.
+func (s *Service) ingestFile(ctx context.Context, file reader.File, in *ingestion) (IngestSummary, error) {
+	modTime := file.ModTime
+	entry := &IngestedFile{
+		FileName:    file.Name,
+		FileSize:    file.Size,
+		FileModTime: &modTime,
+		Checksum:    in.checksum,
+		Status:      FileStatusProcessing,
+		StartedAt:   time.Now(),
+	}
+	if err := s.repository.StartIngestedFile(ctx, entry); err != nil {
+		return IngestSummary{}, unavailableError(fmt.Errorf("register ingested file error %s: %w", file.Name, err))
+	}
+
+	var summary IngestSummary
+	var readErr error
+	err := s.repository.WithinTransaction(ctx, func(ctx context.Context, w Writer) error {
+		summary, readErr = s.readFile(ctx, w, file, in.replace)
+		return readErr
+	})
+	if err != nil && readErr == nil {
+		// The transaction itself failed to begin or commit.
+		err = unavailableError(err)
+	}
+	if err != nil {
+		// The transaction was rolled back, nothing of the file was stored.
+		summary.Inserted, summary.Duplicated, summary.Corrected, summary.Cancelled = 0, 0, 0, 0
+		summary.sessions = nil
+	}
+
+	finishedAt := time.Now()
+	entry.FinishedAt = &finishedAt
+	entry.RowsRead = summary.Rows
+	entry.RowsInserted = summary.Inserted
+	entry.RowsDuplicated = summary.Duplicated
+	entry.RowsRejected = summary.Rejected
+	entry.Status = FileStatusDone
+	if err != nil {
+		entry.Status = FileStatusFailed
+		entry.Error = err.Error()
+	}
+
+	// The ledger is updated even when ctx was cancelled, so the file is retried on the next run.
+	if finishErr := s.repository.FinishIngestedFile(context.WithoutCancel(ctx), entry); finishErr != nil {
+		return summary, errors.Join(err, unavailableError(fmt.Errorf("update ingested file error %s: %w", file.Name, finishErr)))
+	}
+
+	return summary, err
+}
+
+func (s *Service) GetAggregatedData(ctx context.Context, ticker string, startDate, endDate *time.Time, includeLot bool) (*AggregatedData, error) {
+	t, err := ParseTicker(ticker)
+	if err != nil {
+		return nil, err
+	}
+
+	// The standard lot of other tickers is the ticker itself.
+	codes := []string{t.Code}
+	if includeLot && t.Fractional {
+		codes = append(codes, t.Lot())
+	}
+
+	start, end, err := resolvePeriod(time.Now(), startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.repository.GetAggregatedData(ctx, t.Code, codes, start, end)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, &NotFoundError{Ticker: t.Code, StartDate: start, EndDate: end}
+		}
+		return nil, unavailableError(fmt.Errorf("fetching aggregated data error: %w", err))
+	}
+	data.Ticker = t.Code
+	data.StartDate, data.EndDate = start, end
+
+	return data, nil
+}
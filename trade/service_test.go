@@ -3,17 +3,65 @@ package trade_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/gurodrigues-dev/b3-reader/internal/calendar"
+	"github.com/gurodrigues-dev/b3-reader/internal/reader"
 	mock_reader "github.com/gurodrigues-dev/b3-reader/internal/reader/mocks"
 	"github.com/gurodrigues-dev/b3-reader/trade"
 	"github.com/gurodrigues-dev/b3-reader/trade/mocks"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"go.uber.org/zap"
 )
 
+var testFile = reader.File{Path: "test.csv", Name: "test.csv", Size: 128, ModTime: time.Date(2023, 8, 18, 19, 0, 0, 0, time.UTC)}
+
+const testChecksum = "c0ffee"
+
+var testHeader = []string{
+	"DataReferencia", "CodigoInstrumento", "AcaoAtualizacao", "PrecoNegocio", "QuantidadeNegociada", "HoraFechamento",
+	"CodigoIdentificadorNegocio", "TipoSessaoPregao", "DataNegocio", "CodigoParticipanteComprador", "CodigoParticipanteVendedor",
+}
+
+// expectList lists files whose checksum is testChecksum.
+func expectList(csvReader *mock_reader.MockReader, files ...reader.File) {
+	csvReader.EXPECT().List(gomock.Any()).Return(files, nil)
+	csvReader.EXPECT().Checksum(gomock.Any(), gomock.Any()).Return(testChecksum, nil).AnyTimes()
+}
+
+func expectNewFile(repo *mocks.MockRepository) {
+	repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(nil, nil)
+	repo.EXPECT().GetIngestedFileByChecksum(gomock.Any(), testChecksum).Return(nil, nil)
+	repo.EXPECT().StartIngestedFile(gomock.Any(), gomock.Any()).Return(nil)
+	expectTransaction(repo)
+	repo.EXPECT().FinishIngestedFile(gomock.Any(), gomock.Any()).Return(nil)
+}
+
+// newQueryService returns a service over a repository mock, for tests of the
+// query methods, which do not read files.
+func newQueryService(t *testing.T) (*mocks.MockRepository, *trade.Service) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockRepository(ctrl)
+	mockReader := mock_reader.NewMockReader(ctrl)
+	return mockRepo, trade.NewService(mockRepo, mockReader, zap.NewNop())
+}
+
+// expectTransaction runs the transaction callback against the repository mock
+// itself, with no sessions stored before the files read.
+func expectTransaction(repo *mocks.MockRepository) {
+	repo.EXPECT().GetStoredSessions(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	repo.EXPECT().
+		WithinTransaction(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(context.Context, trade.Writer) error) error {
+			return fn(ctx, repo)
+		})
+}
+
 func TestService_IngestFiles(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -23,17 +71,17 @@ func TestService_IngestFiles(t *testing.T) {
 		{
 			name: "successfully ingests files",
 			setupMocks: func(repo *mocks.MockRepository, csvReader *mock_reader.MockReader) {
-				recordsChan := make(chan [][]string, 1)
+				recordsChan := make(chan reader.Chunk, 1)
 				errChan := make(chan error, 1)
 
-				recordsChan <- [][]string{
-					{"header1", "header2", "header3", "header4", "header5", "header6", "header7", "header8", "header9"},
-					{"1", "ABC123", "field3", "123.45", "1000", "123456", "field7", "field8", "2023-08-18"},
-				}
+				recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+					{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+				}}
 				close(recordsChan)
 				close(errChan)
 
-				csvReader.EXPECT().Read(gomock.Any()).Return(recordsChan, errChan)
+				expectList(csvReader, testFile)
+				csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
 
 				repo.EXPECT().
 					SaveBatch(gomock.Any(), gomock.Any()).
@@ -42,37 +90,209 @@ func TestService_IngestFiles(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			name: "keeps first row of chunks after the file header",
+			setupMocks: func(repo *mocks.MockRepository, csvReader *mock_reader.MockReader) {
+				recordsChan := make(chan reader.Chunk, 1)
+				errChan := make(chan error, 1)
+
+				recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 5000, Rows: [][]string{
+					{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+				}}
+				close(recordsChan)
+				close(errChan)
+
+				expectList(csvReader, testFile)
+				csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+
+				repo.EXPECT().
+					SaveBatch(gomock.Any(), gomock.Len(1)).
+					Return(int64(1), nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name: "applies cancellations after saving the chunk",
+			setupMocks: func(repo *mocks.MockRepository, csvReader *mock_reader.MockReader) {
+				recordsChan := make(chan reader.Chunk, 1)
+				errChan := make(chan error, 1)
+
+				recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+					{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+					{"2023-08-18", "ABC123", "0", "124.45", "1000", "123457", "2", "1", "2023-08-18", "3", "72"},
+					{"2023-08-18", "ABC123", "2", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+				}}
+				close(recordsChan)
+				close(errChan)
+
+				expectList(csvReader, testFile)
+				csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+
+				gomock.InOrder(
+					repo.EXPECT().
+						SaveBatch(gomock.Any(), gomock.Len(2)).
+						Return(int64(2), nil),
+					repo.EXPECT().
+						CancelTrades(gomock.Any(), gomock.Len(1)).
+						Return(int64(1), nil),
+				)
+			},
+			expectedError: nil,
+		},
+		{
+			name: "applies corrections after saving the chunk and before cancellations",
+			setupMocks: func(repo *mocks.MockRepository, csvReader *mock_reader.MockReader) {
+				recordsChan := make(chan reader.Chunk, 1)
+				errChan := make(chan error, 1)
+
+				recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+					{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+					{"2023-08-18", "ABC123", "1", "123.40", "900", "123456", "1", "1", "2023-08-18", "3", "72"},
+					{"2023-08-18", "ABC123", "2", "124.45", "1000", "123457", "2", "1", "2023-08-18", "3", "72"},
+				}}
+				close(recordsChan)
+				close(errChan)
+
+				expectList(csvReader, testFile)
+				csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+
+				gomock.InOrder(
+					repo.EXPECT().
+						SaveBatch(gomock.Any(), gomock.Len(1)).
+						Return(int64(1), nil),
+					repo.EXPECT().
+						CorrectTrades(gomock.Any(), gomock.Len(1)).
+						DoAndReturn(func(_ context.Context, corrections []trade.Trade) (int64, error) {
+							assert.Equal(t, "123.4", corrections[0].PrecoNegocio.String())
+							assert.Equal(t, 900, corrections[0].QuantidadeNegociada)
+							return 1, nil
+						}),
+					repo.EXPECT().
+						CancelTrades(gomock.Any(), gomock.Len(1)).
+						Return(int64(1), nil),
+				)
+			},
+			expectedError: nil,
+		},
+		{
+			name: "returns error when correcting trades fails",
+			setupMocks: func(repo *mocks.MockRepository, csvReader *mock_reader.MockReader) {
+				recordsChan := make(chan reader.Chunk, 1)
+				errChan := make(chan error, 1)
+
+				recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+					{"2023-08-18", "ABC123", "1", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+				}}
+				close(recordsChan)
+				close(errChan)
+
+				expectList(csvReader, testFile)
+				csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+
+				repo.EXPECT().
+					CorrectTrades(gomock.Any(), gomock.Any()).
+					Return(int64(0), errors.New("db error"))
+			},
+			expectedError: errors.New("database correct trades error"),
+		},
+		{
+			name: "returns error when cancelling trades fails",
+			setupMocks: func(repo *mocks.MockRepository, csvReader *mock_reader.MockReader) {
+				recordsChan := make(chan reader.Chunk, 1)
+				errChan := make(chan error, 1)
+
+				recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+					{"2023-08-18", "ABC123", "2", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+				}}
+				close(recordsChan)
+				close(errChan)
+
+				expectList(csvReader, testFile)
+				csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+
+				repo.EXPECT().
+					CancelTrades(gomock.Any(), gomock.Any()).
+					Return(int64(0), errors.New("db error"))
+			},
+			expectedError: errors.New("database cancel trades error"),
+		},
 		{
 			name: "returns error when parsing fails",
 			setupMocks: func(_ *mocks.MockRepository, csvReader *mock_reader.MockReader) {
-				recordsChan := make(chan [][]string, 1)
+				recordsChan := make(chan reader.Chunk, 1)
 				errChan := make(chan error, 1)
 
-				recordsChan <- [][]string{
-					{"1", "ABC123", "field3", "bad-float", "1000", "123456", "field7", "field8", "2023-08-18"},
-				}
+				recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+					{"2023-08-18", "ABC123", "0", "bad-float", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+				}}
+				close(recordsChan)
+				close(errChan)
+
+				expectList(csvReader, testFile)
+				csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+			},
+			expectedError: errors.New("parse error preco_negocio at row 2"),
+		},
+		{
+			name: "maps columns by the file header",
+			setupMocks: func(repo *mocks.MockRepository, csvReader *mock_reader.MockReader) {
+				recordsChan := make(chan reader.Chunk, 1)
+				errChan := make(chan error, 1)
+
+				header := append([]string{"Extra"}, testHeader...)
+				header[1], header[2] = header[2], header[1]
+				recordsChan <- reader.Chunk{File: "test.csv", Header: header, Offset: 1, Rows: [][]string{
+					{"x", "ABC123", "2023-08-18", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+				}}
 				close(recordsChan)
 				close(errChan)
 
-				csvReader.EXPECT().Read(gomock.Any()).Return(recordsChan, errChan)
+				expectList(csvReader, testFile)
+				csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+
+				repo.EXPECT().
+					SaveBatch(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, trades []trade.Trade) (int64, error) {
+						if trades[0].CodigoInstrumento != "ABC123" {
+							return 0, errors.New("unexpected ticker " + trades[0].CodigoInstrumento)
+						}
+						return 1, nil
+					})
 			},
 			expectedError: nil,
 		},
+		{
+			name: "returns error when the header misses columns",
+			setupMocks: func(_ *mocks.MockRepository, csvReader *mock_reader.MockReader) {
+				recordsChan := make(chan reader.Chunk, 1)
+				errChan := make(chan error, 1)
+
+				recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader[:10], Offset: 1, Rows: [][]string{
+					{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+				}}
+				close(recordsChan)
+				close(errChan)
+
+				expectList(csvReader, testFile)
+				csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+			},
+			expectedError: errors.New("invalid header in file test.csv: missing columns in header: CodigoParticipanteVendedor"),
+		},
 		{
 			name: "returns error when saving batch fails",
 			setupMocks: func(repo *mocks.MockRepository, csvReader *mock_reader.MockReader) {
-				recordsChan := make(chan [][]string, 1)
+				recordsChan := make(chan reader.Chunk, 1)
 				errChan := make(chan error, 1)
 
-				recordsChan <- [][]string{
-					{"header1", "h2", "h3", "h4", "h5", "h6", "h7", "h8", "h9"},
-					{"1", "ABC123", "field3", "123.45", "1000", "123456", "field7", "field8", "2023-08-18"},
-					{"2", "DEF456", "field3", "678.90", "2000", "234556", "field7", "field8", "2023-08-19"},
-				}
+				recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+					{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+					{"2023-08-19", "DEF456", "0", "678.90", "2000", "234556", "2", "1", "2023-08-19", "3", "72"},
+				}}
 				close(recordsChan)
 				close(errChan)
 
-				csvReader.EXPECT().Read(gomock.Any()).Return(recordsChan, errChan)
+				expectList(csvReader, testFile)
+				csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
 
 				repo.EXPECT().
 					SaveBatch(gomock.Any(), gomock.Any()).
@@ -92,13 +312,14 @@ func TestService_IngestFiles(t *testing.T) {
 			csvReader := mock_reader.NewMockReader(ctrl)
 			logger := zap.NewNop()
 
+			expectNewFile(repo)
 			tt.setupMocks(repo, csvReader)
 
 			service := trade.NewService(repo, csvReader, logger)
 			ctx, cancel := context.WithCancel(t.Context())
 			defer cancel()
 
-			err := service.IngestFiles(ctx, "test.csv")
+			_, err := service.IngestFiles(ctx, "test.csv")
 			if tt.expectedError != nil {
 				assert.ErrorContains(t, err, tt.expectedError.Error())
 			} else {
@@ -108,93 +329,1406 @@ func TestService_IngestFiles(t *testing.T) {
 	}
 }
 
-func TestGetAggregatedData(t *testing.T) {
-	ctx := t.Context()
+func TestService_IngestFiles_ReadError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	csvReader := mock_reader.NewMockReader(ctrl)
 
-	t.Run("expect error when ticker is empty", func(t *testing.T) {
+	recordsChan := make(chan reader.Chunk)
+	errChan := make(chan error, 1)
+	errChan <- errors.New("gzip: invalid checksum")
+
+	expectList(csvReader, testFile)
+	csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+	expectNewFile(repo)
+
+	service := trade.NewService(repo, csvReader, zap.NewNop())
+	_, err := service.IngestFiles(t.Context(), "test.csv")
+
+	assert.ErrorContains(t, err, "file read error: gzip: invalid checksum")
+	assert.ErrorIs(t, err, trade.ErrUnavailable)
+}
+
+func TestService_IngestFiles_RetriesConflict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	csvReader := mock_reader.NewMockReader(ctrl)
+
+	read := func() (<-chan reader.Chunk, <-chan error) {
+		recordsChan := make(chan reader.Chunk, 1)
+		errChan := make(chan error)
+		recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+			{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+		}}
+		close(recordsChan)
+		close(errChan)
+		return recordsChan, errChan
+	}
+
+	expectList(csvReader, testFile)
+	csvReader.EXPECT().Read(gomock.Any(), testFile).DoAndReturn(func(context.Context, reader.File) (<-chan reader.Chunk, <-chan error) {
+		return read()
+	}).Times(2)
+	repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(nil, nil)
+	repo.EXPECT().GetIngestedFileByChecksum(gomock.Any(), testChecksum).Return(nil, nil)
+	repo.EXPECT().StartIngestedFile(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	repo.EXPECT().GetStoredSessions(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	repo.EXPECT().
+		WithinTransaction(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(context.Context, trade.Writer) error) error {
+			return fn(ctx, repo)
+		}).
+		Times(2)
+	gomock.InOrder(
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(1)).Return(int64(0), fmt.Errorf("%w: deadlock detected", trade.ErrConflict)),
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(1)).Return(int64(1), nil),
+	)
+	gomock.InOrder(
+		repo.EXPECT().FinishIngestedFile(gomock.Any(), gomock.Cond(func(entry *trade.IngestedFile) bool {
+			return entry.Status == trade.FileStatusFailed
+		})).Return(nil),
+		repo.EXPECT().FinishIngestedFile(gomock.Any(), gomock.Cond(func(entry *trade.IngestedFile) bool {
+			return entry.Status == trade.FileStatusDone
+		})).Return(nil),
+	)
+
+	service := trade.NewService(repo, csvReader, zap.NewNop())
+	summary, err := service.IngestFiles(t.Context(), "test.csv")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &trade.IngestSummary{Files: 1, Rows: 1, Inserted: 1}, summary)
+}
+
+func TestService_IngestFiles_Summary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+	csvReader := mock_reader.NewMockReader(ctrl)
+
+	recordsChan := make(chan reader.Chunk, 2)
+	errChan := make(chan error, 1)
+
+	recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+		{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+		{"2023-08-18", "ABC123", "0", "124.45", "1000", "123457", "2", "1", "2023-08-18", "3", "72"},
+	}}
+	recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 3, Rows: [][]string{
+		{"2023-08-18", "ABC123", "2", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+	}}
+	close(recordsChan)
+	close(errChan)
+
+	expectList(csvReader, testFile)
+	csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+	expectNewFile(repo)
+	repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(2)).Return(int64(1), nil)
+	repo.EXPECT().CancelTrades(gomock.Any(), gomock.Len(1)).Return(int64(1), nil)
+
+	service := trade.NewService(repo, csvReader, zap.NewNop())
+	summary, err := service.IngestFiles(t.Context(), "test.csv")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &trade.IngestSummary{
+		Files:      1,
+		Rows:       3,
+		Inserted:   1,
+		Duplicated: 1,
+		Cancelled:  1,
+	}, summary)
+}
+
+func TestService_IngestFiles_MissingSessions(t *testing.T) {
+	date := func(day int, month time.Month) time.Time {
+		return time.Date(2025, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	setup := func(t *testing.T) (*mocks.MockRepository, *mock_reader.MockReader) {
 		ctrl := gomock.NewController(t)
-		defer ctrl.Finish()
-		mockRepo := mocks.NewMockRepository(ctrl)
-		mockReader := mock_reader.NewMockReader(ctrl)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
 
-		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+		recordsChan := make(chan reader.Chunk, 1)
+		errChan := make(chan error, 1)
+		recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+			{"2025-03-05", "PETR4", "0", "36.20", "100", "130000000", "2", "1", "2025-03-05", "3", "72"},
+			{"2025-03-07", "PETR4", "0", "36.30", "100", "100000000", "3", "1", "2025-03-07", "3", "72"},
+		}}
+		close(recordsChan)
+		close(errChan)
 
-		data, err := svc.GetAggregatedData(ctx, "", nil)
+		expectList(csvReader, testFile)
+		csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(2)).Return(int64(2), nil)
 
-		assert.Nil(t, data)
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "ticker is required")
+		return repo, csvReader
+	}
+
+	t.Run("checks the dates read against the sessions stored before them", func(t *testing.T) {
+		repo, csvReader := setup(t)
+		// 2025-02-27 was loaded by a previous run. Carnaval (2025-03-03 and
+		// 2025-03-04) has no session, 2025-02-28 and 2025-03-06 are missing.
+		// Only the trades are checked, as no COTAHIST file was read.
+		repo.EXPECT().
+			GetStoredSessions(gomock.Any(), trade.DatasetTrades, date(5, time.March), date(7, time.March)).
+			Return([]time.Time{date(27, time.February), date(5, time.March), date(7, time.March)}, nil)
+		expectNewFile(repo)
+
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []trade.MissingSession{
+			{Dataset: trade.DatasetTrades, Date: date(28, time.February)},
+			{Dataset: trade.DatasetTrades, Date: date(6, time.March)},
+		}, summary.MissingSessions)
+	})
+
+	t.Run("returns unavailable error when stored sessions fail", func(t *testing.T) {
+		repo, csvReader := setup(t)
+		repo.EXPECT().
+			GetStoredSessions(gomock.Any(), trade.DatasetTrades, gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("db error"))
+		expectNewFile(repo)
+
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.ErrorContains(t, err, "fetching stored sessions error trades")
+		assert.ErrorIs(t, err, trade.ErrUnavailable)
+		assert.Equal(t, int64(2), summary.Inserted)
 	})
+}
+
+func TestService_IngestFiles_Ledger(t *testing.T) {
+	newChunks := func() (chan reader.Chunk, chan error) {
+		recordsChan := make(chan reader.Chunk, 1)
+		errChan := make(chan error, 1)
+		recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+			{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+		}}
+		close(recordsChan)
+		close(errChan)
+		return recordsChan, errChan
+	}
 
-	t.Run("use default startdate when data_inicio is nil", func(t *testing.T) {
+	t.Run("skips file already ingested", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
-		defer ctrl.Finish()
-		mockRepo := mocks.NewMockRepository(ctrl)
-		mockReader := mock_reader.NewMockReader(ctrl)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
 
-		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+		expectList(csvReader, testFile)
+		repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(&trade.IngestedFile{
+			FileName: testFile.Name,
+			Checksum: testChecksum,
+			Status:   trade.FileStatusDone,
+		}, nil)
 
-		approxDate := time.Now().AddDate(0, 0, -7)
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
 
-		mockRepo.
-			EXPECT().
-			GetAggregatedData(ctx, "PETR4", gomock.Any()).
-			DoAndReturn(func(_ context.Context, _ string, startDate time.Time) (float64, int, error) {
-				if !startDate.After(approxDate.Add(-2*time.Second)) || !startDate.Before(approxDate.Add(2*time.Second)) {
-					t.Errorf("expected startDate ~ %v, got %v", approxDate, startDate)
-				}
-				return 100.5, 2000, nil
+		assert.NoError(t, err)
+		assert.Equal(t, &trade.IngestSummary{Skipped: 1}, summary)
+	})
+
+	t.Run("skips file with the same size and modification time without reading it", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		modTime := testFile.ModTime
+		csvReader.EXPECT().List(gomock.Any()).Return([]reader.File{testFile}, nil)
+		repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(&trade.IngestedFile{
+			FileName:    testFile.Name,
+			FileSize:    testFile.Size,
+			FileModTime: &modTime,
+			Checksum:    testChecksum,
+			Status:      trade.FileStatusDone,
+		}, nil)
+
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &trade.IngestSummary{Skipped: 1}, summary)
+	})
+
+	t.Run("skips file already ingested under another name", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		expectList(csvReader, testFile)
+		repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(nil, nil)
+		repo.EXPECT().GetIngestedFileByChecksum(gomock.Any(), testChecksum).Return(&trade.IngestedFile{
+			FileName: "2023/test.csv",
+			Checksum: testChecksum,
+			Status:   trade.FileStatusDone,
+		}, nil)
+		repo.EXPECT().
+			StartIngestedFile(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, f *trade.IngestedFile) error {
+				assert.Equal(t, trade.FileStatusSkipped, f.Status)
+				return nil
+			})
+		repo.EXPECT().
+			FinishIngestedFile(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, f *trade.IngestedFile) error {
+				assert.Equal(t, trade.FileStatusSkipped, f.Status)
+				assert.Equal(t, testChecksum, f.Checksum)
+				assert.Equal(t, testFile.ModTime, *f.FileModTime)
+				assert.NotNil(t, f.FinishedAt)
+				return nil
 			})
 
-		data, err := svc.GetAggregatedData(ctx, "PETR4", nil)
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
 
 		assert.NoError(t, err)
-		assert.Equal(t, "PETR4", data.Ticker)
-		assert.Equal(t, 100.5, data.MaxRangeValue)
-		assert.Equal(t, 2000, data.MaxDailyVolume)
+		assert.Equal(t, &trade.IngestSummary{Skipped: 1}, summary)
 	})
 
-	t.Run("when repository return fail", func(t *testing.T) {
+	t.Run("skips file recorded as skipped without reading it", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
-		defer ctrl.Finish()
-		mockRepo := mocks.NewMockRepository(ctrl)
-		mockReader := mock_reader.NewMockReader(ctrl)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
 
-		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+		modTime := testFile.ModTime
+		csvReader.EXPECT().List(gomock.Any()).Return([]reader.File{testFile}, nil)
+		repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(&trade.IngestedFile{
+			FileName:    testFile.Name,
+			FileSize:    testFile.Size,
+			FileModTime: &modTime,
+			Checksum:    testChecksum,
+			Status:      trade.FileStatusSkipped,
+		}, nil)
 
-		startDate := time.Date(2024, 8, 10, 0, 0, 0, 0, time.UTC)
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
 
-		mockRepo.
-			EXPECT().
-			GetAggregatedData(ctx, "VALE3", startDate).
-			Return(0.0, 0, errors.New("db error"))
+		assert.NoError(t, err)
+		assert.Equal(t, &trade.IngestSummary{Skipped: 1}, summary)
+	})
 
-		data, err := svc.GetAggregatedData(ctx, "VALE3", &startDate)
+	t.Run("ingests file recorded as skipped that changed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
 
-		assert.Nil(t, data)
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "fetching aggregated data error")
+		recordsChan, errChan := newChunks()
+		expectList(csvReader, testFile)
+		csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+		repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(&trade.IngestedFile{
+			FileName: testFile.Name,
+			Checksum: "changed",
+			Status:   trade.FileStatusSkipped,
+		}, nil)
+		repo.EXPECT().GetIngestedFileByChecksum(gomock.Any(), testChecksum).Return(nil, nil)
+		repo.EXPECT().StartIngestedFile(gomock.Any(), gomock.Any()).Return(nil)
+		expectTransaction(repo)
+		// The trades stored belong to the file it matched, so they are not replaced.
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(1)).Return(int64(1), nil)
+		repo.EXPECT().FinishIngestedFile(gomock.Any(), gomock.Any()).Return(nil)
+
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), summary.Inserted)
 	})
 
-	t.Run("successfully with data_inicio", func(t *testing.T) {
+	t.Run("returns unavailable error when checksum fails", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
-		defer ctrl.Finish()
-		mockRepo := mocks.NewMockRepository(ctrl)
-		mockReader := mock_reader.NewMockReader(ctrl)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
 
-		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
-		startDate := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+		csvReader.EXPECT().List(gomock.Any()).Return([]reader.File{testFile}, nil)
+		csvReader.EXPECT().Checksum(gomock.Any(), testFile).Return("", errors.New("disk error"))
+		repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(nil, nil)
 
-		mockRepo.
-			EXPECT().
-			GetAggregatedData(ctx, "ITUB4", startDate).
-			Return(55.5, 1200, nil)
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		_, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.ErrorContains(t, err, "checksum file error")
+		assert.ErrorIs(t, err, trade.ErrUnavailable)
+	})
+
+	for _, status := range []string{trade.FileStatusFailed, trade.FileStatusProcessing, trade.FileStatusDone} {
+		t.Run("ingests file with status "+status, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockRepository(ctrl)
+			csvReader := mock_reader.NewMockReader(ctrl)
+
+			recordsChan, errChan := newChunks()
+			expectList(csvReader, testFile)
+			csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+			repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(&trade.IngestedFile{
+				FileName: testFile.Name,
+				Checksum: "changed",
+				Status:   status,
+			}, nil)
+			repo.EXPECT().StartIngestedFile(gomock.Any(), gomock.Any()).Return(nil)
+			expectTransaction(repo)
+			// Trades stored by a previous version of the file are replaced.
+			repo.EXPECT().ReplaceBatch(gomock.Any(), gomock.Len(1)).Return(int64(1), nil)
+			repo.EXPECT().
+				FinishIngestedFile(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, f *trade.IngestedFile) error {
+					assert.Equal(t, trade.FileStatusDone, f.Status)
+					assert.Equal(t, testChecksum, f.Checksum)
+					assert.Equal(t, testFile.ModTime, *f.FileModTime)
+					assert.Equal(t, int64(1), f.RowsRead)
+					assert.Equal(t, int64(1), f.RowsInserted)
+					assert.NotNil(t, f.FinishedAt)
+					return nil
+				})
+
+			service := trade.NewService(repo, csvReader, zap.NewNop())
+			summary, err := service.IngestFiles(t.Context(), "test.csv")
+
+			assert.NoError(t, err)
+			assert.Equal(t, 1, summary.Files)
+		})
+	}
+
+	t.Run("records failed status when ingestion fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		recordsChan, errChan := newChunks()
+		expectList(csvReader, testFile)
+		csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+		repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(nil, nil)
+		repo.EXPECT().GetIngestedFileByChecksum(gomock.Any(), testChecksum).Return(nil, nil)
+		repo.EXPECT().StartIngestedFile(gomock.Any(), gomock.Any()).Return(nil)
+		expectTransaction(repo)
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Any()).Return(int64(0), errors.New("db error"))
+		repo.EXPECT().
+			FinishIngestedFile(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, f *trade.IngestedFile) error {
+				assert.Equal(t, trade.FileStatusFailed, f.Status)
+				assert.Contains(t, f.Error, "db error")
+				return nil
+			})
+
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		_, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.ErrorContains(t, err, "database save batch error")
+		assert.ErrorIs(t, err, trade.ErrUnavailable)
+	})
+
+	t.Run("discards counts of a rolled back file", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		recordsChan := make(chan reader.Chunk, 2)
+		errChan := make(chan error, 1)
+		recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{
+			{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+		}}
+		recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 2, Rows: [][]string{
+			{"2023-08-18", "ABC123", "0", "bad-float", "1000", "123456", "2", "1", "2023-08-18", "3", "72"},
+		}}
+		close(recordsChan)
+		close(errChan)
+
+		expectList(csvReader, testFile)
+		csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+		repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(nil, nil)
+		repo.EXPECT().GetIngestedFileByChecksum(gomock.Any(), testChecksum).Return(nil, nil)
+		repo.EXPECT().StartIngestedFile(gomock.Any(), gomock.Any()).Return(nil)
+		expectTransaction(repo)
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(1)).Return(int64(1), nil).MaxTimes(1)
+		repo.EXPECT().
+			FinishIngestedFile(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, f *trade.IngestedFile) error {
+				assert.Equal(t, trade.FileStatusFailed, f.Status)
+				assert.Equal(t, int64(0), f.RowsInserted)
+				return nil
+			})
+
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.ErrorContains(t, err, "parse error preco_negocio at row 3")
+		assert.Equal(t, int64(0), summary.Inserted)
+	})
+
+	t.Run("returns error when ledger lookup fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		expectList(csvReader, testFile)
+		repo.EXPECT().GetIngestedFile(gomock.Any(), testFile.Name).Return(nil, errors.New("db error"))
+
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		_, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.ErrorContains(t, err, "fetching ingested file error")
+	})
+}
+
+func TestService_IngestFiles_ErrorPolicy(t *testing.T) {
+	valid := []string{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"}
+	invalid := []string{"2023-08-18", "ABC123", "0", "bad-float", "1000", "123456", "2", "1", "2023-08-18", "3", "72"}
+	file := testFile
+	file.Sep = ';'
+
+	setup := func(t *testing.T, rows ...[]string) (*mocks.MockRepository, *mock_reader.MockReader) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		recordsChan := make(chan reader.Chunk, 1)
+		errChan := make(chan error, 1)
+		recordsChan <- reader.Chunk{File: file.Path, Header: testHeader, Offset: 1, Rows: rows}
+		close(recordsChan)
+		close(errChan)
+
+		expectList(csvReader, file)
+		csvReader.EXPECT().Read(gomock.Any(), file).Return(recordsChan, errChan)
+		expectNewFile(repo)
+
+		return repo, csvReader
+	}
+
+	t.Run("skip drops invalid rows", func(t *testing.T) {
+		repo, csvReader := setup(t, valid, invalid)
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(1)).Return(int64(1), nil)
 
-		data, err := svc.GetAggregatedData(ctx, "ITUB4", &startDate)
+		service := trade.NewService(repo, csvReader, zap.NewNop(), trade.WithErrorPolicy(trade.ErrorPolicySkip, 10))
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
 
 		assert.NoError(t, err)
-		assert.Equal(t, "ITUB4", data.Ticker)
-		assert.Equal(t, 55.5, data.MaxRangeValue)
-		assert.Equal(t, 1200, data.MaxDailyVolume)
+		assert.Equal(t, int64(2), summary.Rows)
+		assert.Equal(t, int64(1), summary.Inserted)
+		assert.Equal(t, int64(1), summary.Rejected)
+	})
+
+	t.Run("quarantine stores invalid rows", func(t *testing.T) {
+		repo, csvReader := setup(t, valid, invalid)
+		repo.EXPECT().
+			SaveRejected(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, rejected []trade.RejectedTrade) (int64, error) {
+				assert.Len(t, rejected, 1)
+				assert.Equal(t, "test.csv", rejected[0].FileName)
+				assert.Equal(t, 3, rejected[0].LineNumber)
+				assert.Equal(t, "2023-08-18;ABC123;0;bad-float;1000;123456;2;1;2023-08-18;3;72", rejected[0].RawLine)
+				assert.Contains(t, rejected[0].Reason, "parse error preco_negocio at row 3")
+				return 1, nil
+			})
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(1)).Return(int64(1), nil)
+
+		service := trade.NewService(repo, csvReader, zap.NewNop(), trade.WithErrorPolicy(trade.ErrorPolicyQuarantine, 0))
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), summary.Rejected)
+	})
+
+	t.Run("fails the file above the rejected rows limit", func(t *testing.T) {
+		repo, csvReader := setup(t, valid, invalid, invalid)
+		// The rows are kept although the file is rolled back.
+		repo.EXPECT().SaveRejected(gomock.Any(), gomock.Len(2)).Return(int64(2), nil)
+
+		service := trade.NewService(repo, csvReader, zap.NewNop(), trade.WithErrorPolicy(trade.ErrorPolicyQuarantine, 1))
+		_, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.ErrorContains(t, err, "exceed the limit of 1")
+	})
+
+	t.Run("rejects rows the reader could not split", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		recordsChan := make(chan reader.Chunk, 2)
+		errChan := make(chan error, 1)
+		recordsChan <- reader.Chunk{File: file.Path, Header: testHeader, Offset: 1, Rows: [][]string{valid},
+			Invalid: &reader.InvalidRow{Line: `x;"y`, Err: errors.New(`parse error on line 3, column 9: bare " in non-quoted-field`)}}
+		recordsChan <- reader.Chunk{File: file.Path, Header: testHeader, Offset: 3, Rows: [][]string{valid}}
+		close(recordsChan)
+		close(errChan)
+
+		expectList(csvReader, file)
+		csvReader.EXPECT().Read(gomock.Any(), file).Return(recordsChan, errChan)
+		expectNewFile(repo)
+		repo.EXPECT().
+			SaveRejected(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, rejected []trade.RejectedTrade) (int64, error) {
+				assert.Len(t, rejected, 1)
+				assert.Equal(t, 3, rejected[0].LineNumber)
+				assert.Equal(t, `x;"y`, rejected[0].RawLine)
+				assert.Contains(t, rejected[0].Reason, "bare")
+				return 1, nil
+			})
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Any()).Return(int64(1), nil).Times(2)
+
+		service := trade.NewService(repo, csvReader, zap.NewNop(), trade.WithErrorPolicy(trade.ErrorPolicyQuarantine, 10))
+		summary, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), summary.Rows)
+		assert.Equal(t, int64(1), summary.Rejected)
+	})
+
+	t.Run("fail-fast aborts on the first invalid row", func(t *testing.T) {
+		repo, csvReader := setup(t, valid, invalid)
+
+		service := trade.NewService(repo, csvReader, zap.NewNop())
+		_, err := service.IngestFiles(t.Context(), "test.csv")
+
+		assert.ErrorContains(t, err, "parse error in file test.csv")
+		assert.ErrorIs(t, err, trade.ErrValidation)
+	})
+}
+
+func TestService_IngestFiles_CancellationBeforeTrade(t *testing.T) {
+	cancellation := reader.File{Path: "a.csv", Name: "a.csv"}
+	original := reader.File{Path: "b.csv", Name: "b.csv"}
+
+	chunksOf := func(row []string) (chan reader.Chunk, chan error) {
+		recordsChan := make(chan reader.Chunk, 1)
+		errChan := make(chan error, 1)
+		recordsChan <- reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: [][]string{row}}
+		close(recordsChan)
+		close(errChan)
+		return recordsChan, errChan
+	}
+
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	csvReader := mock_reader.NewMockReader(ctrl)
+
+	expectList(csvReader, cancellation, original)
+	csvReader.EXPECT().Read(gomock.Any(), cancellation).Return(chunksOf(
+		[]string{"2023-08-18", "ABC123", "2", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+	))
+	csvReader.EXPECT().Read(gomock.Any(), original).Return(chunksOf(
+		[]string{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+	))
+	repo.EXPECT().GetIngestedFile(gomock.Any(), gomock.Any()).Return(nil, nil).Times(2)
+	repo.EXPECT().GetIngestedFileByChecksum(gomock.Any(), gomock.Any()).Return(nil, nil).Times(2)
+	repo.EXPECT().StartIngestedFile(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	repo.EXPECT().FinishIngestedFile(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	repo.EXPECT().GetStoredSessions(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	repo.EXPECT().
+		WithinTransaction(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(context.Context, trade.Writer) error) error {
+			return fn(ctx, repo)
+		}).
+		Times(2)
+
+	gomock.InOrder(
+		// The cancellation carries the whole trade, as it is stored in place
+		// of the original until the original is read.
+		repo.EXPECT().
+			CancelTrades(gomock.Any(), gomock.Len(1)).
+			DoAndReturn(func(_ context.Context, cancellations []trade.Trade) (int64, error) {
+				assert.Equal(t, int64(1), cancellations[0].CodigoIdentificadorNegocio)
+				assert.Equal(t, "123.45", cancellations[0].PrecoNegocio.String())
+				assert.Equal(t, 1000, cancellations[0].QuantidadeNegociada)
+				assert.False(t, cancellations[0].DataHoraNegocio.IsZero())
+				return 1, nil
+			}),
+		// The original finds the cancelled trade already stored and keeps it.
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(1)).Return(int64(0), nil),
+	)
+
+	service := trade.NewService(repo, csvReader, zap.NewNop(), trade.WithWorkers(1))
+	summary, err := service.IngestFiles(t.Context(), "input")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Files)
+	assert.Equal(t, int64(1), summary.Cancelled)
+	assert.Equal(t, int64(1), summary.Duplicated)
+	assert.Equal(t, int64(0), summary.Inserted)
+}
+
+func TestService_IngestFiles_Workers(t *testing.T) {
+	files := []reader.File{
+		{Path: "a.csv", Name: "a.csv"},
+		{Path: "b.csv", Name: "b.csv"},
+		{Path: "c.csv", Name: "c.csv"},
+	}
+	tickers := map[string]string{"a.csv": "PETR4", "b.csv": "VALE3", "c.csv": "ITUB4"}
+
+	chunksFor := func(ticker string) (chan reader.Chunk, chan error) {
+		recordsChan := make(chan reader.Chunk, 1)
+		errChan := make(chan error, 1)
+		recordsChan <- reader.Chunk{File: ticker, Header: testHeader, Offset: 1, Rows: [][]string{
+			{"2023-08-18", ticker, "0", "123.45", "1000", "123456", "1", "1", "2023-08-18", "3", "72"},
+		}}
+		close(recordsChan)
+		close(errChan)
+		return recordsChan, errChan
+	}
+
+	setup := func(t *testing.T) (*mocks.MockRepository, *mock_reader.MockReader) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		expectList(csvReader, files...)
+		for _, f := range files {
+			csvReader.EXPECT().
+				Read(gomock.Any(), f).
+				DoAndReturn(func(_ context.Context, f reader.File) (<-chan reader.Chunk, <-chan error) {
+					return chunksFor(tickers[f.Name])
+				}).
+				MaxTimes(1)
+		}
+		repo.EXPECT().GetIngestedFile(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+		repo.EXPECT().GetIngestedFileByChecksum(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+		repo.EXPECT().StartIngestedFile(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		repo.EXPECT().FinishIngestedFile(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		repo.EXPECT().GetStoredSessions(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+		repo.EXPECT().
+			WithinTransaction(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(context.Context, trade.Writer) error) error {
+				return fn(ctx, repo)
+			}).
+			AnyTimes()
+
+		return repo, csvReader
+	}
+
+	t.Run("ingests every file", func(t *testing.T) {
+		repo, csvReader := setup(t)
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(1)).Return(int64(1), nil).Times(3)
+
+		service := trade.NewService(repo, csvReader, zap.NewNop(), trade.WithWorkers(2))
+		summary, err := service.IngestFiles(t.Context(), "input")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, summary.Files)
+		assert.Equal(t, int64(3), summary.Inserted)
+	})
+
+	t.Run("reports the failing file and cancels the others", func(t *testing.T) {
+		repo, csvReader := setup(t)
+		repo.EXPECT().
+			SaveBatch(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, trades []trade.Trade) (int64, error) {
+				if trades[0].CodigoInstrumento == "VALE3" {
+					return 0, errors.New("db error")
+				}
+				return 1, nil
+			}).
+			AnyTimes()
+
+		service := trade.NewService(repo, csvReader, zap.NewNop(), trade.WithWorkers(3))
+		_, err := service.IngestFiles(t.Context(), "input")
+
+		assert.ErrorContains(t, err, "database save batch error")
+		assert.NotErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("stops on context cancellation", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		expectList(csvReader, files...)
+		repo.EXPECT().GetIngestedFile(gomock.Any(), gomock.Any()).Return(nil, context.Canceled).AnyTimes()
+
+		service := trade.NewService(repo, csvReader, zap.NewNop(), trade.WithWorkers(2))
+		_, err := service.IngestFiles(ctx, "input")
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestService_IngestFiles_Pipeline(t *testing.T) {
+	row := func(id int) []string {
+		return []string{"2023-08-18", "ABC123", "0", "123.45", "1000", "123456", strconv.Itoa(id), "1", "2023-08-18", "3", "72"}
+	}
+	rows := func(from, n int) [][]string {
+		out := make([][]string, n)
+		for i := range out {
+			out[i] = row(from + i)
+		}
+		return out
+	}
+
+	setup := func(t *testing.T, chunks ...reader.Chunk) (*mocks.MockRepository, *trade.Service) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		recordsChan := make(chan reader.Chunk, len(chunks))
+		errChan := make(chan error, 1)
+		for _, c := range chunks {
+			recordsChan <- c
+		}
+		close(recordsChan)
+		close(errChan)
+
+		expectList(csvReader, testFile)
+		csvReader.EXPECT().Read(gomock.Any(), testFile).Return(recordsChan, errChan)
+		expectNewFile(repo)
+
+		return repo, trade.NewService(repo, csvReader, zap.NewNop())
+	}
+
+	t.Run("groups rows of several chunks in one batch", func(t *testing.T) {
+		repo, service := setup(t,
+			reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: rows(1, 3)},
+			reader.Chunk{File: "test.csv", Header: testHeader, Offset: 4, Rows: rows(4, 3)},
+		)
+		repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(6)).Return(int64(6), nil)
+
+		_, err := service.IngestFiles(t.Context(), "test.csv")
+		assert.NoError(t, err)
+	})
+
+	t.Run("splits rows in batches of the batch size", func(t *testing.T) {
+		repo, service := setup(t,
+			reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: rows(1, 3000)},
+			reader.Chunk{File: "test.csv", Header: testHeader, Offset: 3001, Rows: rows(3001, 3000)},
+		)
+		gomock.InOrder(
+			repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(5000)).Return(int64(5000), nil),
+			repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(1000)).Return(int64(1000), nil),
+		)
+
+		_, err := service.IngestFiles(t.Context(), "test.csv")
+		assert.NoError(t, err)
+	})
+
+	t.Run("flushes pending trades before cancellations", func(t *testing.T) {
+		cancellation := row(1)
+		cancellation[2] = "2"
+		repo, service := setup(t,
+			reader.Chunk{File: "test.csv", Header: testHeader, Offset: 1, Rows: rows(1, 2)},
+			reader.Chunk{File: "test.csv", Header: testHeader, Offset: 3, Rows: [][]string{cancellation, row(3)}},
+		)
+		gomock.InOrder(
+			repo.EXPECT().SaveBatch(gomock.Any(), gomock.Len(3)).Return(int64(3), nil),
+			repo.EXPECT().CancelTrades(gomock.Any(), gomock.Len(1)).Return(int64(1), nil),
+		)
+
+		_, err := service.IngestFiles(t.Context(), "test.csv")
+		assert.NoError(t, err)
+	})
+}
+
+func TestService_IngestFiles_Layouts(t *testing.T) {
+	// setup lists file and expects it to be read as read, which differs only in the format.
+	setup := func(t *testing.T, file, read reader.File, chunk reader.Chunk) (*mocks.MockRepository, *trade.Service) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		csvReader := mock_reader.NewMockReader(ctrl)
+
+		recordsChan := make(chan reader.Chunk, 1)
+		errChan := make(chan error, 1)
+		recordsChan <- chunk
+		close(recordsChan)
+		close(errChan)
+
+		expectList(csvReader, file)
+		csvReader.EXPECT().Read(gomock.Any(), read).Return(recordsChan, errChan)
+		repo.EXPECT().GetIngestedFile(gomock.Any(), file.Name).Return(nil, nil)
+		repo.EXPECT().GetIngestedFileByChecksum(gomock.Any(), testChecksum).Return(nil, nil)
+		repo.EXPECT().StartIngestedFile(gomock.Any(), gomock.Any()).Return(nil)
+		expectTransaction(repo)
+		repo.EXPECT().FinishIngestedFile(gomock.Any(), gomock.Any()).Return(nil)
+
+		return repo, trade.NewService(repo, csvReader, zap.NewNop())
+	}
+
+	t.Run("loads the instrument registry", func(t *testing.T) {
+		file := reader.File{Path: "InstrumentsConsolidatedFile_20240816_1.csv", Name: "InstrumentsConsolidatedFile_20240816_1.csv"}
+		repo, service := setup(t, file, file, reader.Chunk{
+			File:   file.Path,
+			Header: []string{"RptDt", "TckrSymb", "Asst", "SgmtNm", "MktNm", "SctyCtgyNm", "ISIN", "XprtnDt", "CrpnNm"},
+			Offset: 1,
+			Rows: [][]string{
+				{"2024-08-16", "PETR4", "PETR", "CASH", "EQUITY-CASH", "SHARES", "BRPETRACNPR6", "", "PETROBRAS"},
+				{"2024-08-16", "VALE3", "VALE", "CASH", "EQUITY-CASH", "SHARES", "BRVALEACNOR0", "", "VALE S.A."},
+			},
+		})
+		repo.EXPECT().SaveInstruments(gomock.Any(), gomock.Len(2)).Return(int64(2), nil)
+
+		summary, err := service.IngestFiles(t.Context(), "input")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), summary.Rows)
+		assert.Equal(t, int64(2), summary.Inserted)
+	})
+
+	t.Run("reads COTAHIST files as fixed-width", func(t *testing.T) {
+		file := reader.File{Path: "COTAHIST_D16082024.TXT", Name: "COTAHIST_D16082024.TXT"}
+		fixedWidth := file
+		fixedWidth.Format = reader.FormatFixedWidth
+
+		line := "012024081602PETR4       010PETROBRAS   PN      N2   R$  " +
+			"0000000003650000000000371200000000036400000000003688000000000370500000000037000000000003701" +
+			"54321000000000041234500000000152077236000000000000000009999123100000010000000000000BRPETRACNPR6123"
+		repo, service := setup(t, file, fixedWidth, reader.Chunk{
+			File:   file.Path,
+			Offset: 0,
+			Rows:   [][]string{{"00COTAHIST.2024BOVESPA 20240816"}, {line}, {"99COTAHIST.2024BOVESPA 2024081600000000003"}},
+		})
+		repo.EXPECT().
+			SaveDailyBars(gomock.Any(), gomock.Len(1)).
+			DoAndReturn(func(_ context.Context, bars []trade.DailyBar) (int64, error) {
+				if bars[0].CodigoInstrumento != "PETR4" || !bars[0].PrecoFechamento.Equal(decimal.RequireFromString("37.05")) {
+					return 0, errors.New("unexpected bar")
+				}
+				return 1, nil
+			})
+
+		summary, err := service.IngestFiles(t.Context(), "input")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), summary.Rows)
+		assert.Equal(t, int64(1), summary.Inserted)
+	})
+
+	t.Run("fails files of an unknown layout", func(t *testing.T) {
+		file := reader.File{Path: "notes.csv", Name: "notes.csv"}
+		_, service := setup(t, file, file, reader.Chunk{
+			File:   file.Path,
+			Header: []string{"Data", "Nota"},
+			Offset: 1,
+			Rows:   [][]string{{"2024-08-16", "x"}},
+		})
+
+		_, err := service.IngestFiles(t.Context(), "input")
+
+		assert.ErrorContains(t, err, "unknown layout for file notes.csv")
+	})
+}
+
+func TestParseErrorPolicy(t *testing.T) {
+	for _, name := range []string{"fail-fast", "skip", "quarantine"} {
+		policy, err := trade.ParseErrorPolicy(name)
+		assert.NoError(t, err)
+		assert.Equal(t, trade.ErrorPolicy(name), policy)
+	}
+
+	_, err := trade.ParseErrorPolicy("ignore")
+	assert.ErrorContains(t, err, "invalid error policy")
+}
+
+func TestGetAggregatedData(t *testing.T) {
+	ctx := t.Context()
+
+	t.Run("expect error when ticker is empty", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockReader := mock_reader.NewMockReader(ctrl)
+
+		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+
+		data, err := svc.GetAggregatedData(ctx, "", nil, nil, false)
+
+		assert.Nil(t, data)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "ticker is required")
+		assert.ErrorIs(t, err, trade.ErrValidation)
+	})
+
+	t.Run("use the last sessions when no dates are given", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockReader := mock_reader.NewMockReader(ctrl)
+
+		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+
+		mockRepo.
+			EXPECT().
+			GetAggregatedData(ctx, "PETR4", []string{"PETR4"}, gomock.Any(), gomock.Any()).
+			Return(&trade.AggregatedData{MaxRangeValue: decimal.RequireFromString("100.5"), MaxDailyVolume: 2000}, nil)
+
+		data, err := svc.GetAggregatedData(ctx, "PETR4", nil, nil, false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "PETR4", data.Ticker)
+		assert.Equal(t, "100.5", data.MaxRangeValue.String())
+		assert.Equal(t, 2000, data.MaxDailyVolume)
+
+		assert.False(t, data.EndDate.After(time.Now()))
+		assert.True(t, time.Since(data.EndDate) < 7*24*time.Hour)
+		assert.True(t, calendar.IsSession(data.StartDate))
+		assert.True(t, calendar.IsSession(data.EndDate))
+		assert.Len(t, calendar.Sessions(data.StartDate, data.EndDate), 7)
+	})
+
+	t.Run("use the sessions before data_fim", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockReader := mock_reader.NewMockReader(ctrl)
+
+		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+
+		// A Sunday: the period ends on it and covers the 7 sessions up to the Friday before.
+		endDate := time.Date(2024, 8, 18, 0, 0, 0, 0, time.UTC)
+		startDate := time.Date(2024, 8, 8, 0, 0, 0, 0, time.UTC)
+
+		mockRepo.
+			EXPECT().
+			GetAggregatedData(ctx, "PETR4", []string{"PETR4"}, startDate, endDate).
+			Return(&trade.AggregatedData{}, nil)
+
+		data, err := svc.GetAggregatedData(ctx, "PETR4", nil, &endDate, false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, startDate, data.StartDate)
+		assert.Equal(t, endDate, data.EndDate)
+	})
+
+	t.Run("include the standard lot of a fractional ticker", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockReader := mock_reader.NewMockReader(ctrl)
+
+		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+
+		startDate := time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC)
+		endDate := time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC)
+
+		mockRepo.
+			EXPECT().
+			GetAggregatedData(ctx, "PETR4F", []string{"PETR4F", "PETR4"}, startDate, endDate).
+			Return(&trade.AggregatedData{}, nil)
+		// A standard lot ticker has no other lot to include.
+		mockRepo.
+			EXPECT().
+			GetAggregatedData(ctx, "PETR4", []string{"PETR4"}, startDate, endDate).
+			Return(&trade.AggregatedData{}, nil)
+
+		data, err := svc.GetAggregatedData(ctx, "petr4f", &startDate, &endDate, true)
+		assert.NoError(t, err)
+		assert.Equal(t, "PETR4F", data.Ticker)
+
+		data, err = svc.GetAggregatedData(ctx, "PETR4", &startDate, &endDate, true)
+		assert.NoError(t, err)
+		assert.Equal(t, "PETR4", data.Ticker)
+	})
+
+	t.Run("invalid periods", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockReader := mock_reader.NewMockReader(ctrl)
+
+		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+
+		startDate := time.Date(2024, 8, 10, 0, 0, 0, 0, time.UTC)
+		before := time.Date(2024, 8, 9, 0, 0, 0, 0, time.UTC)
+		tooFar := time.Date(2025, 8, 12, 0, 0, 0, 0, time.UTC)
+
+		_, err := svc.GetAggregatedData(ctx, "PETR4", &startDate, &before, false)
+		assert.ErrorIs(t, err, trade.ErrValidation)
+		assert.EqualError(t, err, "data_inicio 2024-08-10 is after data_fim 2024-08-09")
+
+		_, err = svc.GetAggregatedData(ctx, "PETR4", &startDate, &tooFar, false)
+		assert.ErrorIs(t, err, trade.ErrValidation)
+		assert.EqualError(t, err, "period from 2024-08-10 to 2025-08-12 is longer than 366 days")
+	})
+
+	t.Run("when repository return fail", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockReader := mock_reader.NewMockReader(ctrl)
+
+		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+
+		startDate := time.Date(2024, 8, 10, 0, 0, 0, 0, time.UTC)
+		endDate := time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC)
+
+		mockRepo.
+			EXPECT().
+			GetAggregatedData(ctx, "VALE3", []string{"VALE3"}, startDate, endDate).
+			Return(nil, errors.New("db error"))
+
+		data, err := svc.GetAggregatedData(ctx, "VALE3", &startDate, &endDate, false)
+
+		assert.Nil(t, data)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "fetching aggregated data error")
+		assert.ErrorIs(t, err, trade.ErrUnavailable)
+	})
+
+	t.Run("ticker without trades in the period", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockReader := mock_reader.NewMockReader(ctrl)
+
+		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+
+		startDate := time.Date(2024, 8, 10, 0, 0, 0, 0, time.UTC)
+		endDate := time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC)
+
+		mockRepo.
+			EXPECT().
+			GetAggregatedData(ctx, "VALE3", []string{"VALE3"}, startDate, endDate).
+			Return(nil, trade.ErrNotFound)
+
+		data, err := svc.GetAggregatedData(ctx, "vale3", &startDate, &endDate, false)
+
+		assert.Nil(t, data)
+		assert.ErrorIs(t, err, trade.ErrNotFound)
+		var notFound *trade.NotFoundError
+		if assert.ErrorAs(t, err, &notFound) {
+			assert.Equal(t, "VALE3", notFound.Ticker)
+			assert.Equal(t, startDate, notFound.StartDate)
+			assert.Equal(t, endDate, notFound.EndDate)
+		}
+		assert.EqualError(t, err, "no trades found for ticker VALE3 from 2024-08-10 to 2024-08-16")
+	})
+
+	t.Run("successfully with data_inicio and data_fim", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockReader := mock_reader.NewMockReader(ctrl)
+
+		svc := trade.NewService(mockRepo, mockReader, zap.NewNop())
+		startDate := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+		endDate := time.Date(2024, 8, 9, 0, 0, 0, 0, time.UTC)
+
+		mockRepo.
+			EXPECT().
+			GetAggregatedData(ctx, "ITUB4", []string{"ITUB4"}, startDate, endDate).
+			Return(&trade.AggregatedData{
+				MaxDailyVolume:     1200,
+				MaxDailyVolumeDate: time.Date(2024, 8, 5, 0, 0, 0, 0, time.UTC),
+				MaxRangeValue:      decimal.RequireFromString("55.5"),
+				MinRangeValue:      decimal.RequireFromString("51.2"),
+				OpenPrice:          decimal.RequireFromString("52"),
+				ClosePrice:         decimal.RequireFromString("54.75"),
+				TotalVolume:        5400,
+				TradeCount:         37,
+			}, nil)
+
+		data, err := svc.GetAggregatedData(ctx, "ITUB4", &startDate, &endDate, false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "ITUB4", data.Ticker)
+		assert.Equal(t, startDate, data.StartDate)
+		assert.Equal(t, endDate, data.EndDate)
+		assert.Equal(t, "55.5", data.MaxRangeValue.String())
+		assert.Equal(t, "51.2", data.MinRangeValue.String())
+		assert.Equal(t, "52", data.OpenPrice.String())
+		assert.Equal(t, "54.75", data.ClosePrice.String())
+		assert.Equal(t, 1200, data.MaxDailyVolume)
+		assert.Equal(t, time.Date(2024, 8, 5, 0, 0, 0, 0, time.UTC), data.MaxDailyVolumeDate)
+		assert.Equal(t, int64(5400), data.TotalVolume)
+		assert.Equal(t, int64(37), data.TradeCount)
+	})
+}
+
+func TestGetAggregatedDataBatch(t *testing.T) {
+	ctx := t.Context()
+	startDate := time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC)
+
+	t.Run("invalid requests", func(t *testing.T) {
+		_, svc := newQueryService(t)
+
+		tooMany := make([]string, 201)
+		for i := range tooMany {
+			tooMany[i] = "PETR4"
+		}
+
+		tests := []struct {
+			name    string
+			tickers []string
+			wantErr string
+		}{
+			{"no tickers", nil, "tickers are required"},
+			{"too many tickers", tooMany, "too many tickers: 201, the limit is 200"},
+			{"invalid ticker", []string{"PETR4", "PETR-4"}, `invalid ticker "PETR-4"`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				data, err := svc.GetAggregatedDataBatch(ctx, tt.tickers, &startDate, &endDate)
+
+				assert.Nil(t, data)
+				assert.ErrorIs(t, err, trade.ErrValidation)
+				assert.EqualError(t, err, tt.wantErr)
+			})
+		}
+	})
+
+	t.Run("keeps the requested order and lists tickers without trades", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		mockRepo.
+			EXPECT().
+			GetAggregatedDataBatch(ctx, []string{"VALE3", "PETR4", "XPTO3"}, startDate, endDate).
+			Return([]trade.AggregatedData{
+				{Ticker: "PETR4", MaxRangeValue: decimal.RequireFromString("31.5"), TradeCount: 10},
+				{Ticker: "VALE3", MaxRangeValue: decimal.RequireFromString("60.1"), TradeCount: 20},
+			}, nil)
+
+		batch, err := svc.GetAggregatedDataBatch(ctx, []string{"vale3", "PETR4", " petr4", "XPTO3"}, &startDate, &endDate)
+
+		assert.NoError(t, err)
+		assert.Equal(t, startDate, batch.StartDate)
+		assert.Equal(t, endDate, batch.EndDate)
+		if assert.Len(t, batch.Data, 2) {
+			assert.Equal(t, "VALE3", batch.Data[0].Ticker)
+			assert.Equal(t, "PETR4", batch.Data[1].Ticker)
+			assert.Equal(t, startDate, batch.Data[1].StartDate)
+			assert.Equal(t, endDate, batch.Data[1].EndDate)
+		}
+		assert.Equal(t, []string{"XPTO3"}, batch.NotFound)
+	})
+
+	t.Run("when repository return fail", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		mockRepo.
+			EXPECT().
+			GetAggregatedDataBatch(ctx, []string{"PETR4"}, startDate, endDate).
+			Return(nil, errors.New("db error"))
+
+		batch, err := svc.GetAggregatedDataBatch(ctx, []string{"PETR4"}, &startDate, &endDate)
+
+		assert.Nil(t, batch)
+		assert.ErrorIs(t, err, trade.ErrUnavailable)
+		assert.ErrorContains(t, err, "fetching batch aggregated data error")
+	})
+}
+
+func TestGetDailyStats(t *testing.T) {
+	ctx := t.Context()
+	startDate := time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC)
+
+	t.Run("invalid ticker", func(t *testing.T) {
+		_, svc := newQueryService(t)
+
+		series, err := svc.GetDailyStats(ctx, "PETR", &startDate, &endDate)
+
+		assert.Nil(t, series)
+		assert.ErrorIs(t, err, trade.ErrValidation)
+	})
+
+	t.Run("ticker without trades in the period", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		mockRepo.EXPECT().GetDailyStats(ctx, "XPTO3", startDate, endDate).Return(nil, nil)
+
+		series, err := svc.GetDailyStats(ctx, "xpto3", &startDate, &endDate)
+
+		assert.Nil(t, series)
+		assert.ErrorIs(t, err, trade.ErrNotFound)
+		assert.EqualError(t, err, "no trades found for ticker XPTO3 from 2024-08-12 to 2024-08-16")
+	})
+
+	t.Run("when repository return fail", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		mockRepo.EXPECT().GetDailyStats(ctx, "PETR4", startDate, endDate).Return(nil, errors.New("db error"))
+
+		series, err := svc.GetDailyStats(ctx, "PETR4", &startDate, &endDate)
+
+		assert.Nil(t, series)
+		assert.ErrorIs(t, err, trade.ErrUnavailable)
+		assert.ErrorContains(t, err, "fetching daily stats error")
+	})
+
+	t.Run("successfully", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		sessions := []trade.DailyStats{
+			{Date: startDate, Open: decimal.RequireFromString("36.1"), VWAP: decimal.RequireFromString("36.2"), Volume: 100, TradeCount: 3},
+			{Date: endDate, Open: decimal.RequireFromString("36.5"), VWAP: decimal.RequireFromString("36.4"), Volume: 200, TradeCount: 5},
+		}
+		mockRepo.EXPECT().GetDailyStats(ctx, "PETR4", startDate, endDate).Return(sessions, nil)
+
+		series, err := svc.GetDailyStats(ctx, "PETR4", &startDate, &endDate)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &trade.DailySeries{
+			Ticker:    "PETR4",
+			StartDate: startDate,
+			EndDate:   endDate,
+			Sessions:  sessions,
+		}, series)
+	})
+}
+
+func TestGetCandles(t *testing.T) {
+	ctx := t.Context()
+	date := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+
+	t.Run("invalid ticker", func(t *testing.T) {
+		_, svc := newQueryService(t)
+
+		series, err := svc.GetCandles(ctx, "PETR", &date, "")
+
+		assert.Nil(t, series)
+		assert.ErrorIs(t, err, trade.ErrValidation)
+	})
+
+	t.Run("invalid interval", func(t *testing.T) {
+		_, svc := newQueryService(t)
+
+		series, err := svc.GetCandles(ctx, "PETR4", &date, "2m")
+
+		assert.Nil(t, series)
+		assert.ErrorIs(t, err, trade.ErrValidation)
+		assert.EqualError(t, err, `invalid interval "2m", use 1m, 5m, 15m or 1h`)
+	})
+
+	t.Run("defaults to the last session and 5 minutes", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+		assert.NoError(t, err)
+		lastSession := calendar.LastSession(time.Now().In(saoPaulo))
+		mockRepo.EXPECT().GetCandles(ctx, "PETR4", lastSession, 5*time.Minute).Return(nil, nil)
+
+		series, err := svc.GetCandles(ctx, "petr4", nil, "")
+
+		assert.Nil(t, series)
+		assert.ErrorIs(t, err, trade.ErrNotFound)
+		assert.EqualError(t, err, fmt.Sprintf("no trades found for ticker PETR4 from %[1]s to %[1]s", lastSession.Format("2006-01-02")))
+	})
+
+	t.Run("when repository return fail", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		mockRepo.EXPECT().GetCandles(ctx, "PETR4", date, time.Hour).Return(nil, errors.New("db error"))
+
+		series, err := svc.GetCandles(ctx, "PETR4", &date, trade.CandleInterval1h)
+
+		assert.Nil(t, series)
+		assert.ErrorIs(t, err, trade.ErrUnavailable)
+		assert.ErrorContains(t, err, "fetching candles error")
+	})
+
+	t.Run("successfully", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		start := time.Date(2025, 8, 6, 13, 0, 0, 0, time.UTC)
+		mockRepo.EXPECT().GetCandles(ctx, "PETR4", date, 15*time.Minute).Return([]trade.Candle{
+			{Time: start, Open: decimal.RequireFromString("36.1"), Close: decimal.RequireFromString("36.2"), Volume: 100, TradeCount: 3},
+		}, nil)
+
+		series, err := svc.GetCandles(ctx, "PETR4", &date, trade.CandleInterval15m)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "PETR4", series.Ticker)
+		assert.Equal(t, date, series.Date)
+		assert.Equal(t, trade.CandleInterval15m, series.Interval)
+		assert.Len(t, series.Candles, 1)
+		assert.True(t, series.Candles[0].Time.Equal(start))
+		assert.Equal(t, "2025-08-06T10:00:00-03:00", series.Candles[0].Time.Format(time.RFC3339))
+	})
+}
+
+func TestGetAveragePrices(t *testing.T) {
+	ctx := t.Context()
+	startDate := time.Date(2025, 8, 5, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+
+	sessions := func() []trade.SessionAverages {
+		return []trade.SessionAverages{
+			{Date: startDate, AveragePrice: trade.AveragePrice{VWAP: decimal.RequireFromString("36.1"), TWAP: decimal.RequireFromString("36.05"), Volume: 300, TradeCount: 4}},
+			{Date: endDate, AveragePrice: trade.AveragePrice{VWAP: decimal.RequireFromString("36.4"), TWAP: decimal.RequireFromString("36.42"), Volume: 200, TradeCount: 2}},
+		}
+	}
+
+	t.Run("invalid ticker", func(t *testing.T) {
+		_, svc := newQueryService(t)
+
+		result, err := svc.GetAveragePrices(ctx, "PETR", &startDate, &endDate, "")
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, trade.ErrValidation)
+	})
+
+	t.Run("invalid interval", func(t *testing.T) {
+		_, svc := newQueryService(t)
+
+		result, err := svc.GetAveragePrices(ctx, "PETR4", &startDate, &endDate, "30m")
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, trade.ErrValidation)
+	})
+
+	t.Run("ticker without trades in the period", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		mockRepo.EXPECT().GetSessionAverages(ctx, "XPTO3", startDate, endDate).Return(nil, nil)
+
+		result, err := svc.GetAveragePrices(ctx, "xpto3", &startDate, &endDate, trade.CandleInterval5m)
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, trade.ErrNotFound)
+		assert.EqualError(t, err, "no trades found for ticker XPTO3 from 2025-08-05 to 2025-08-06")
+	})
+
+	t.Run("when repository return fail", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		mockRepo.EXPECT().GetSessionAverages(ctx, "PETR4", startDate, endDate).Return(sessions(), nil)
+		mockRepo.EXPECT().GetWindowAverages(ctx, "PETR4", startDate, endDate, time.Hour).Return(nil, errors.New("db error"))
+
+		result, err := svc.GetAveragePrices(ctx, "PETR4", &startDate, &endDate, trade.CandleInterval1h)
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, trade.ErrUnavailable)
+		assert.ErrorContains(t, err, "fetching window averages error")
+	})
+
+	t.Run("sessions only without interval", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		mockRepo.EXPECT().GetSessionAverages(ctx, "PETR4", startDate, endDate).Return(sessions(), nil)
+
+		result, err := svc.GetAveragePrices(ctx, "PETR4", &startDate, &endDate, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &trade.AveragePrices{
+			Ticker:    "PETR4",
+			StartDate: startDate,
+			EndDate:   endDate,
+			Sessions:  sessions(),
+		}, result)
+	})
+
+	t.Run("windows grouped by session", func(t *testing.T) {
+		mockRepo, svc := newQueryService(t)
+
+		// The database returns window times in UTC; they are matched to the
+		// sessions in São Paulo time.
+		windows := []trade.WindowAverages{
+			{Time: time.Date(2025, 8, 5, 13, 0, 0, 0, time.UTC), AveragePrice: trade.AveragePrice{Volume: 100}},
+			{Time: time.Date(2025, 8, 5, 14, 0, 0, 0, time.UTC), AveragePrice: trade.AveragePrice{Volume: 200}},
+			{Time: time.Date(2025, 8, 6, 12, 45, 0, 0, time.UTC), AveragePrice: trade.AveragePrice{Volume: 200}},
+		}
+		mockRepo.EXPECT().GetSessionAverages(ctx, "PETR4", startDate, endDate).Return(sessions(), nil)
+		mockRepo.EXPECT().GetWindowAverages(ctx, "PETR4", startDate, endDate, 15*time.Minute).Return(windows, nil)
+
+		result, err := svc.GetAveragePrices(ctx, "PETR4", &startDate, &endDate, trade.CandleInterval15m)
+
+		assert.NoError(t, err)
+		assert.Equal(t, trade.CandleInterval15m, result.Interval)
+		assert.Len(t, result.Sessions, 2)
+		assert.Len(t, result.Sessions[0].Windows, 2)
+		assert.Len(t, result.Sessions[1].Windows, 1)
+		assert.Equal(t, "2025-08-06T09:45:00-03:00", result.Sessions[1].Windows[0].Time.Format(time.RFC3339))
+		assert.Equal(t, int64(200), result.Sessions[1].Windows[0].Volume)
 	})
 }
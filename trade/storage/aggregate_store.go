@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gurodrigues-dev/b3-reader/trade"
+)
+
+// aggregateQuery aggregates the trades of the instruments in $1 over the period
+// from $2 to $3 in a single grouped query, each under the ticker at the same
+// position in $4, one row per ticker with trades.
+// Open and close come from the first and last trades by time, using the trade
+// id to break ties, and the day of the largest volume is the earliest on ties.
+const aggregateQuery = `
+	WITH filtered AS (
+		SELECT requested.ticker AS codigo_instrumento, data_negocio, data_hora_negocio, codigo_identificador_negocio, preco_negocio, quantidade_negociada
+		FROM trades
+		JOIN unnest($1::text[], $4::text[]) AS requested(code, ticker) ON trades.codigo_instrumento = requested.code
+		WHERE trades.codigo_instrumento = ANY($1)
+			AND data_negocio BETWEEN $2 AND $3
+			AND NOT cancelado
+	),
+	totals AS (
+		SELECT
+			codigo_instrumento,
+			COUNT(*) AS trade_count,
+			SUM(quantidade_negociada) AS total_volume,
+			MAX(preco_negocio) AS max_range_value,
+			MIN(preco_negocio) AS min_range_value
+		FROM filtered
+		GROUP BY codigo_instrumento
+	),
+	opening AS (
+		SELECT DISTINCT ON (codigo_instrumento) codigo_instrumento, preco_negocio
+		FROM filtered
+		ORDER BY codigo_instrumento, data_hora_negocio, codigo_identificador_negocio
+	),
+	closing AS (
+		SELECT DISTINCT ON (codigo_instrumento) codigo_instrumento, preco_negocio
+		FROM filtered
+		ORDER BY codigo_instrumento, data_hora_negocio DESC, codigo_identificador_negocio DESC
+	),
+	peak AS (
+		SELECT DISTINCT ON (codigo_instrumento) codigo_instrumento, data_negocio, volume
+		FROM (
+			SELECT codigo_instrumento, data_negocio, SUM(quantidade_negociada) AS volume
+			FROM filtered
+			GROUP BY codigo_instrumento, data_negocio
+		) daily
+		ORDER BY codigo_instrumento, volume DESC, data_negocio
+	)
+	SELECT
+		t.codigo_instrumento,
+		t.trade_count,
+		t.total_volume,
+		t.max_range_value,
+		t.min_range_value,
+		o.preco_negocio AS open_price,
+		c.preco_negocio AS close_price,
+		p.volume AS max_daily_volume,
+		p.data_negocio AS max_daily_volume_date
+	FROM totals t
+	JOIN opening o USING (codigo_instrumento)
+	JOIN closing c USING (codigo_instrumento)
+	JOIN peak p USING (codigo_instrumento)
+	ORDER BY t.codigo_instrumento;
+`
+
+// GetAggregatedData aggregates the trades of the instruments in codes over the
+// whole period under ticker.
+func (r *TradeRepository) GetAggregatedData(ctx context.Context, ticker string, codes []string, startDate, endDate time.Time) (*trade.AggregatedData, error) {
+	tickers := make([]string, len(codes))
+	for i := range codes {
+		tickers[i] = ticker
+	}
+
+	data, err := r.queryAggregates(ctx, codes, tickers, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, trade.ErrNotFound
+	}
+
+	return &data[0], nil
+}
+
+// GetAggregatedDataBatch aggregates the trades of several tickers at once,
+// leaving out the tickers without trades in the period.
+func (r *TradeRepository) GetAggregatedDataBatch(ctx context.Context, tickers []string, startDate, endDate time.Time) ([]trade.AggregatedData, error) {
+	return r.queryAggregates(ctx, tickers, tickers, startDate, endDate)
+}
+
+// queryAggregates runs the aggregate query, grouping the trades of each code
+// under the ticker at the same position.
+func (r *TradeRepository) queryAggregates(ctx context.Context, codes, tickers []string, startDate, endDate time.Time) ([]trade.AggregatedData, error) {
+	rows, err := r.db.Query(ctx, aggregateQuery, codes, startDate, endDate, tickers)
+	if err != nil {
+		return nil, fmt.Errorf("error querying aggregated data: %w", err)
+	}
+	defer rows.Close()
+
+	var result []trade.AggregatedData
+	for rows.Next() {
+		var data trade.AggregatedData
+		var maxDailyVolume int64
+		err := rows.Scan(
+			&data.Ticker,
+			&data.TradeCount,
+			&data.TotalVolume,
+			scanDecimal(&data.MaxRangeValue),
+			scanDecimal(&data.MinRangeValue),
+			scanDecimal(&data.OpenPrice),
+			scanDecimal(&data.ClosePrice),
+			&maxDailyVolume,
+			&data.MaxDailyVolumeDate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning aggregated data: %w", err)
+		}
+		data.MaxDailyVolume = int(maxDailyVolume)
+
+		result = append(result, data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying aggregated data: %w", err)
+	}
+
+	return result, nil
+}
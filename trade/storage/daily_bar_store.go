@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/gurodrigues-dev/b3-reader/trade"
+)
+
+var dailyBarColumns = []string{
+	"data_pregao",
+	"codigo_bdi",
+	"codigo_instrumento",
+	"tipo_mercado",
+	"preco_abertura",
+	"preco_maximo",
+	"preco_minimo",
+	"preco_medio",
+	"preco_fechamento",
+	"numero_negocios",
+	"quantidade_total",
+	"volume_total",
+	"fator_cotacao",
+	"isin",
+}
+
+// SaveDailyBars upserts the daily bars, so reloading a corrected COTAHIST file
+// replaces the quotes previously stored.
+func (r *TradeRepository) SaveDailyBars(ctx context.Context, bars []trade.DailyBar) (int64, error) {
+	if len(bars) == 0 {
+		return 0, nil
+	}
+
+	// Only the last row of a bar in the file is kept, since ON CONFLICT DO
+	// UPDATE cannot touch the same row twice in one statement.
+	latest := make(map[dailyBarKey]int, len(bars))
+	var rows [][]interface{}
+	for _, b := range bars {
+		row := []interface{}{
+			b.DataPregao,
+			b.CodigoBDI,
+			b.CodigoInstrumento,
+			b.TipoMercado,
+			numeric(b.PrecoAbertura),
+			numeric(b.PrecoMaximo),
+			numeric(b.PrecoMinimo),
+			numeric(b.PrecoMedio),
+			numeric(b.PrecoFechamento),
+			b.NumeroNegocios,
+			b.QuantidadeTotal,
+			numeric(b.VolumeTotal),
+			b.FatorCotacao,
+			b.ISIN,
+		}
+		key := dailyBarKey{b.DataPregao, b.CodigoInstrumento, b.TipoMercado}
+		if i, ok := latest[key]; ok {
+			rows[i] = row
+			continue
+		}
+		latest[key] = len(rows)
+		rows = append(rows, row)
+	}
+
+	return r.upsertRows(ctx, "daily_bars", "daily_bars_staging", dailyBarColumns, rows, "", `
+		ON CONFLICT (data_pregao, codigo_instrumento, tipo_mercado) DO UPDATE SET
+			codigo_bdi = EXCLUDED.codigo_bdi,
+			preco_abertura = EXCLUDED.preco_abertura,
+			preco_maximo = EXCLUDED.preco_maximo,
+			preco_minimo = EXCLUDED.preco_minimo,
+			preco_medio = EXCLUDED.preco_medio,
+			preco_fechamento = EXCLUDED.preco_fechamento,
+			numero_negocios = EXCLUDED.numero_negocios,
+			quantidade_total = EXCLUDED.quantidade_total,
+			volume_total = EXCLUDED.volume_total,
+			fator_cotacao = EXCLUDED.fator_cotacao,
+			isin = EXCLUDED.isin`,
+	)
+}
+
+// dailyBarKey is the key of a daily bar.
+type dailyBarKey struct {
+	dataPregao        time.Time
+	codigoInstrumento string
+	tipoMercado       int
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/gurodrigues-dev/b3-reader/trade"
+)
+
+var instrumentColumns = []string{
+	"data_referencia",
+	"codigo_instrumento",
+	"ativo",
+	"segmento",
+	"mercado",
+	"categoria",
+	"isin",
+	"data_vencimento",
+	"razao_social",
+}
+
+// SaveInstruments copies the instruments into a temporary staging table and
+// upserts them into instruments, so reloading a registry file refreshes it.
+func (r *TradeRepository) SaveInstruments(ctx context.Context, instruments []trade.Instrument) (int64, error) {
+	if len(instruments) == 0 {
+		return 0, nil
+	}
+
+	// Only the last row of an instrument in the file is kept, since ON CONFLICT
+	// DO UPDATE cannot touch the same row twice in one statement.
+	latest := make(map[instrumentKey]int, len(instruments))
+	var rows [][]interface{}
+	for _, in := range instruments {
+		row := []interface{}{
+			in.DataReferencia,
+			in.CodigoInstrumento,
+			in.Ativo,
+			in.Segmento,
+			in.Mercado,
+			in.Categoria,
+			in.ISIN,
+			in.DataVencimento,
+			in.RazaoSocial,
+		}
+		key := instrumentKey{in.DataReferencia, in.CodigoInstrumento}
+		if i, ok := latest[key]; ok {
+			rows[i] = row
+			continue
+		}
+		latest[key] = len(rows)
+		rows = append(rows, row)
+	}
+
+	return r.upsertRows(ctx, "instruments", "instruments_staging", instrumentColumns, rows, "", `
+		ON CONFLICT (data_referencia, codigo_instrumento) DO UPDATE SET
+			ativo = EXCLUDED.ativo,
+			segmento = EXCLUDED.segmento,
+			mercado = EXCLUDED.mercado,
+			categoria = EXCLUDED.categoria,
+			isin = EXCLUDED.isin,
+			data_vencimento = EXCLUDED.data_vencimento,
+			razao_social = EXCLUDED.razao_social`,
+	)
+}
+
+// instrumentKey is the key of an instrument in the registry.
+type instrumentKey struct {
+	dataReferencia    time.Time
+	codigoInstrumento string
+}
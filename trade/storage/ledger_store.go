@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gurodrigues-dev/b3-reader/trade"
+	"github.com/jackc/pgx/v5"
+)
+
+const ingestedFileColumns = `
+	id, file_name, file_size, file_mod_time, checksum, rows_read, rows_inserted,
+	rows_duplicated, rows_rejected, status, error, started_at, finished_at`
+
+func (r *TradeRepository) GetIngestedFile(ctx context.Context, fileName string) (*trade.IngestedFile, error) {
+	query := `
+		SELECT` + ingestedFileColumns + `
+		FROM ingested_files
+		WHERE file_name = $1;
+	`
+
+	return scanIngestedFile(r.db.QueryRow(ctx, query, fileName))
+}
+
+// GetIngestedFileByChecksum finds the most recent file loaded with the given
+// content, under any name.
+func (r *TradeRepository) GetIngestedFileByChecksum(ctx context.Context, checksum string) (*trade.IngestedFile, error) {
+	query := `
+		SELECT` + ingestedFileColumns + `
+		FROM ingested_files
+		WHERE checksum = $1 AND status = $2
+		ORDER BY finished_at DESC
+		LIMIT 1;
+	`
+
+	return scanIngestedFile(r.db.QueryRow(ctx, query, checksum, trade.FileStatusDone))
+}
+
+func scanIngestedFile(row pgx.Row) (*trade.IngestedFile, error) {
+	var f trade.IngestedFile
+	err := row.Scan(
+		&f.ID,
+		&f.FileName,
+		&f.FileSize,
+		&f.FileModTime,
+		&f.Checksum,
+		&f.RowsRead,
+		&f.RowsInserted,
+		&f.RowsDuplicated,
+		&f.RowsRejected,
+		&f.Status,
+		&f.Error,
+		&f.StartedAt,
+		&f.FinishedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying ingested file: %w", err)
+	}
+
+	return &f, nil
+}
+
+func (r *TradeRepository) StartIngestedFile(ctx context.Context, file *trade.IngestedFile) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction error: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Rows quarantined by a previous attempt are read again.
+	if _, err := tx.Exec(ctx, "DELETE FROM rejected_trades WHERE file_name = $1", file.FileName); err != nil {
+		return fmt.Errorf("sql delete rejected trades error: %w", err)
+	}
+
+	query := `
+		INSERT INTO ingested_files (file_name, file_size, file_mod_time, checksum, status, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (file_name) DO UPDATE SET
+			file_size = EXCLUDED.file_size,
+			file_mod_time = EXCLUDED.file_mod_time,
+			checksum = EXCLUDED.checksum,
+			rows_read = 0,
+			rows_inserted = 0,
+			rows_duplicated = 0,
+			rows_rejected = 0,
+			status = EXCLUDED.status,
+			error = '',
+			started_at = EXCLUDED.started_at,
+			finished_at = NULL
+		RETURNING id;
+	`
+
+	err = tx.QueryRow(ctx, query,
+		file.FileName,
+		file.FileSize,
+		file.FileModTime,
+		file.Checksum,
+		file.Status,
+		file.StartedAt,
+	).Scan(&file.ID)
+	if err != nil {
+		return fmt.Errorf("sql start ingested file error: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction error: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TradeRepository) FinishIngestedFile(ctx context.Context, file *trade.IngestedFile) error {
+	query := `
+		UPDATE ingested_files SET
+			rows_read = $2,
+			rows_inserted = $3,
+			rows_duplicated = $4,
+			rows_rejected = $5,
+			status = $6,
+			error = $7,
+			finished_at = $8
+		WHERE id = $1;
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		file.ID,
+		file.RowsRead,
+		file.RowsInserted,
+		file.RowsDuplicated,
+		file.RowsRejected,
+		file.Status,
+		file.Error,
+		file.FinishedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("sql finish ingested file error: %w", err)
+	}
+
+	return nil
+}
+
+// sessionColumns holds the table and trading date column of each dataset.
+var sessionColumns = map[trade.Dataset][2]string{
+	trade.DatasetTrades:    {"trades", "data_negocio"},
+	trade.DatasetDailyBars: {"daily_bars", "data_pregao"},
+}
+
+// GetStoredSessions walks the index on the trading date one distinct date at a
+// time, so the cost depends on the number of dates rather than rows.
+func (r *TradeRepository) GetStoredSessions(ctx context.Context, dataset trade.Dataset, startDate, endDate time.Time) ([]time.Time, error) {
+	target, ok := sessionColumns[dataset]
+	if !ok {
+		return nil, fmt.Errorf("unknown dataset %q", dataset)
+	}
+
+	query := fmt.Sprintf(`
+		WITH RECURSIVE days AS (
+			SELECT COALESCE(
+				(SELECT max(%[2]s) FROM %[1]s WHERE %[2]s < $1),
+				(SELECT min(%[2]s) FROM %[1]s WHERE %[2]s >= $1)
+			) AS day
+			UNION ALL
+			SELECT (SELECT min(%[2]s) FROM %[1]s WHERE %[2]s > days.day)
+			FROM days
+			WHERE days.day < $2
+		)
+		SELECT day FROM days WHERE day IS NOT NULL AND day <= $2;
+	`, target[0], target[1])
+
+	rows, err := r.db.Query(ctx, query, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("error querying stored sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("error scanning stored session: %w", err)
+		}
+		days = append(days, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stored sessions: %w", err)
+	}
+
+	return days, nil
+}
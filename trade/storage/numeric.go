@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/shopspring/decimal"
+)
+
+// numeric converts a decimal to the NUMERIC representation of pgx without
+// going through float64, so values are stored exactly.
+func numeric(d decimal.Decimal) pgtype.Numeric {
+	return pgtype.Numeric{Int: d.Coefficient(), Exp: d.Exponent(), Valid: true}
+}
+
+// fromNumeric converts a NUMERIC read from the database to a decimal. NULL is
+// read as zero.
+func fromNumeric(n pgtype.Numeric) (decimal.Decimal, error) {
+	if !n.Valid {
+		return decimal.Zero, nil
+	}
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return decimal.Decimal{}, fmt.Errorf("numeric value out of range")
+	}
+
+	return decimal.NewFromBigInt(n.Int, n.Exp), nil
+}
+
+// decimalScanner lets pgx scan a NUMERIC column straight into a decimal, as
+// fromNumeric does.
+type decimalScanner struct {
+	d *decimal.Decimal
+}
+
+func scanDecimal(d *decimal.Decimal) decimalScanner {
+	return decimalScanner{d: d}
+}
+
+func (s decimalScanner) ScanNumeric(n pgtype.Numeric) error {
+	v, err := fromNumeric(n)
+	if err != nil {
+		return err
+	}
+
+	*s.d = v
+	return nil
+}
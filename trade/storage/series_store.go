@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gurodrigues-dev/b3-reader/internal/calendar"
+	"github.com/gurodrigues-dev/b3-reader/trade"
+)
+
+// GetDailyStats summarizes the trades of a ticker per session. Open and close
+// come from the first and last trades of each session by time, using the trade
+// id to break ties.
+func (r *TradeRepository) GetDailyStats(ctx context.Context, ticker string, startDate, endDate time.Time) ([]trade.DailyStats, error) {
+	query := `
+		WITH filtered AS (
+			SELECT data_negocio, data_hora_negocio, codigo_identificador_negocio, preco_negocio, quantidade_negociada
+			FROM trades
+			WHERE codigo_instrumento = $1
+				AND data_negocio BETWEEN $2 AND $3
+				AND NOT cancelado
+		),
+		opening AS (
+			SELECT DISTINCT ON (data_negocio) data_negocio, preco_negocio
+			FROM filtered
+			ORDER BY data_negocio, data_hora_negocio, codigo_identificador_negocio
+		),
+		closing AS (
+			SELECT DISTINCT ON (data_negocio) data_negocio, preco_negocio
+			FROM filtered
+			ORDER BY data_negocio, data_hora_negocio DESC, codigo_identificador_negocio DESC
+		)
+		SELECT
+			f.data_negocio,
+			o.preco_negocio AS open,
+			MAX(f.preco_negocio) AS high,
+			MIN(f.preco_negocio) AS low,
+			c.preco_negocio AS close,
+			ROUND(SUM(f.preco_negocio * f.quantidade_negociada) / SUM(f.quantidade_negociada), 8) AS vwap,
+			SUM(f.quantidade_negociada) AS volume,
+			COUNT(*) AS trade_count
+		FROM filtered f
+		JOIN opening o USING (data_negocio)
+		JOIN closing c USING (data_negocio)
+		GROUP BY f.data_negocio, o.preco_negocio, c.preco_negocio
+		ORDER BY f.data_negocio;
+	`
+
+	rows, err := r.db.Query(ctx, query, ticker, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("error querying daily stats: %w", err)
+	}
+	defer rows.Close()
+
+	var result []trade.DailyStats
+	for rows.Next() {
+		var day trade.DailyStats
+		err := rows.Scan(
+			&day.Date,
+			scanDecimal(&day.Open),
+			scanDecimal(&day.High),
+			scanDecimal(&day.Low),
+			scanDecimal(&day.Close),
+			scanDecimal(&day.VWAP),
+			&day.Volume,
+			&day.TradeCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning daily stats: %w", err)
+		}
+
+		result = append(result, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying daily stats: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetCandles summarizes the trades of a ticker in a session per interval.
+// Intervals are aligned to midnight in São Paulo, where B3 trades.
+func (r *TradeRepository) GetCandles(ctx context.Context, ticker string, date time.Time, interval time.Duration) ([]trade.Candle, error) {
+	query := `
+		WITH filtered AS (
+			SELECT
+				date_bin($3::integer * INTERVAL '1 second', data_hora_negocio, TIMESTAMPTZ '2000-01-01 00:00:00-03') AS bucket,
+				data_hora_negocio,
+				codigo_identificador_negocio,
+				preco_negocio,
+				quantidade_negociada
+			FROM trades
+			WHERE codigo_instrumento = $1
+				AND data_negocio = $2
+				AND NOT cancelado
+		),
+		opening AS (
+			SELECT DISTINCT ON (bucket) bucket, preco_negocio
+			FROM filtered
+			ORDER BY bucket, data_hora_negocio, codigo_identificador_negocio
+		),
+		closing AS (
+			SELECT DISTINCT ON (bucket) bucket, preco_negocio
+			FROM filtered
+			ORDER BY bucket, data_hora_negocio DESC, codigo_identificador_negocio DESC
+		)
+		SELECT
+			f.bucket,
+			o.preco_negocio AS open,
+			MAX(f.preco_negocio) AS high,
+			MIN(f.preco_negocio) AS low,
+			c.preco_negocio AS close,
+			SUM(f.quantidade_negociada) AS volume,
+			COUNT(*) AS trade_count
+		FROM filtered f
+		JOIN opening o USING (bucket)
+		JOIN closing c USING (bucket)
+		GROUP BY f.bucket, o.preco_negocio, c.preco_negocio
+		ORDER BY f.bucket;
+	`
+
+	rows, err := r.db.Query(ctx, query, ticker, date, int64(interval/time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("error querying candles: %w", err)
+	}
+	defer rows.Close()
+
+	var result []trade.Candle
+	for rows.Next() {
+		var candle trade.Candle
+		err := rows.Scan(
+			&candle.Time,
+			scanDecimal(&candle.Open),
+			scanDecimal(&candle.High),
+			scanDecimal(&candle.Low),
+			scanDecimal(&candle.Close),
+			&candle.Volume,
+			&candle.TradeCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning candles: %w", err)
+		}
+
+		result = append(result, candle)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying candles: %w", err)
+	}
+
+	return result, nil
+}
+
+// sessionCloses lists every day from startDate to endDate with the end of its
+// regular B3 session, as taken from the trading calendar.
+func sessionCloses(startDate, endDate time.Time) ([]time.Time, []time.Time) {
+	var days, closes []time.Time
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+		closes = append(closes, calendar.SessionClose(day))
+	}
+	return days, closes
+}
+
+// averagesQuery calculates the VWAP and TWAP of a ticker per bucket, the first
+// expression formatted in, within each session. The price of a trade holds
+// until the next trade of its bucket, and the last one until the end of the
+// bucket, the second expression, which can use the session_close of its day
+// given by the days and closes in $4 and $5. No trade holds past the end, so
+// trades after it hold no time, and TWAP falls back to the plain average of
+// the prices when the bucket spans no time.
+const averagesQuery = `
+	WITH filtered AS (
+		SELECT
+			data_negocio,
+			%[1]s AS bucket,
+			data_hora_negocio,
+			preco_negocio,
+			quantidade_negociada,
+			EXTRACT(EPOCH FROM GREATEST(
+				LEAST(
+					COALESCE(
+						LEAD(data_hora_negocio) OVER (
+							PARTITION BY data_negocio, %[1]s
+							ORDER BY data_hora_negocio, codigo_identificador_negocio
+						),
+						%[2]s
+					),
+					%[2]s
+				) - data_hora_negocio,
+				INTERVAL '0'
+			)) AS held_seconds
+		FROM trades
+		JOIN unnest($4::date[], $5::timestamptz[]) AS schedule(data_negocio, session_close) USING (data_negocio)
+		WHERE codigo_instrumento = $1
+			AND data_negocio BETWEEN $2 AND $3
+			AND NOT cancelado
+	)
+	SELECT
+		data_negocio,
+		bucket,
+		ROUND(SUM(preco_negocio * quantidade_negociada) / SUM(quantidade_negociada), 8) AS vwap,
+		ROUND(COALESCE(
+			SUM(preco_negocio * held_seconds) / NULLIF(SUM(held_seconds), 0),
+			AVG(preco_negocio)
+		), 8) AS twap,
+		SUM(quantidade_negociada) AS volume,
+		COUNT(*) AS trade_count
+	FROM filtered
+	GROUP BY data_negocio, bucket
+	ORDER BY data_negocio, bucket;
+`
+
+// GetSessionAverages calculates the VWAP and TWAP of a ticker per session. The
+// last trade of a session holds its price until the session close.
+func (r *TradeRepository) GetSessionAverages(ctx context.Context, ticker string, startDate, endDate time.Time) ([]trade.SessionAverages, error) {
+	days, closes := sessionCloses(startDate, endDate)
+
+	var result []trade.SessionAverages
+	err := r.queryAverages(ctx, fmt.Sprintf(averagesQuery, "data_negocio", "session_close"), func(date, _ time.Time, avg trade.AveragePrice) {
+		result = append(result, trade.SessionAverages{Date: date, AveragePrice: avg})
+	}, ticker, startDate, endDate, days, closes)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetWindowAverages calculates the VWAP and TWAP of a ticker per intraday
+// window. Windows are aligned to midnight in São Paulo, like the candles. The
+// last trade of a window holds its price until the end of the window, or the
+// session close when it comes first.
+func (r *TradeRepository) GetWindowAverages(ctx context.Context, ticker string, startDate, endDate time.Time, interval time.Duration) ([]trade.WindowAverages, error) {
+	bucket := "date_bin($6::integer * INTERVAL '1 second', data_hora_negocio, TIMESTAMPTZ '2000-01-01 00:00:00-03')"
+	end := fmt.Sprintf("LEAST(%s + $6::integer * INTERVAL '1 second', session_close)", bucket)
+	days, closes := sessionCloses(startDate, endDate)
+
+	var result []trade.WindowAverages
+	err := r.queryAverages(ctx, fmt.Sprintf(averagesQuery, bucket, end), func(_, start time.Time, avg trade.AveragePrice) {
+		result = append(result, trade.WindowAverages{Time: start, AveragePrice: avg})
+	}, ticker, startDate, endDate, days, closes, int64(interval/time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// queryAverages runs an averages query, calling add with the session, the
+// bucket and the averages of each row.
+func (r *TradeRepository) queryAverages(ctx context.Context, query string, add func(date, bucket time.Time, avg trade.AveragePrice), args ...any) error {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error querying average prices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			date, bucket time.Time
+			avg          trade.AveragePrice
+		)
+		err := rows.Scan(
+			&date,
+			&bucket,
+			scanDecimal(&avg.VWAP),
+			scanDecimal(&avg.TWAP),
+			&avg.Volume,
+			&avg.TradeCount,
+		)
+		if err != nil {
+			return fmt.Errorf("error scanning average prices: %w", err)
+		}
+
+		add(date, bucket, avg)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error querying average prices: %w", err)
+	}
+
+	return nil
+}
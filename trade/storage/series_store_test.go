@@ -0,0 +1,573 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/gurodrigues-dev/b3-reader/trade"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// testPool connects to the database at TEST_DATABASE_URL, applying the
+// migrations. Tests are skipped without a database.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" || testing.Short() {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	m, err := migrate.New("file://../../database/migrations", url)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrate error: %v", err)
+	}
+
+	pool, err := pgxpool.New(t.Context(), url)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+// testRepository binds the repository to a transaction rolled back at the end
+// of the test, as WithinTransaction does for a file.
+func testRepository(t *testing.T) *TradeRepository {
+	t.Helper()
+
+	return beginRepository(t, testPool(t))
+}
+
+func beginRepository(t *testing.T, pool *pgxpool.Pool) *TradeRepository {
+	t.Helper()
+
+	tx, err := pool.Begin(t.Context())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// t.Context is already canceled when cleanups run.
+	t.Cleanup(func() { _ = tx.Rollback(context.Background()) })
+
+	return &TradeRepository{db: tx, staged: make(map[string]bool), locked: make(map[time.Time]bool)}
+}
+
+func TestTradeRepository_Averages_LastTradeHoldsUntilTheEnd(t *testing.T) {
+	repo := testRepository(t)
+
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// In New York standard time the session closes at 18:00.
+	day := time.Date(2025, 12, 3, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 12, 3, hour, minute, 0, 0, saoPaulo)
+	}
+
+	// 10 holds from 17:00 to 17:30 and 20 from 17:30 to the session close.
+	trades := []trade.Trade{
+		{DataNegocio: day, DataReferencia: day, CodigoInstrumento: "TWAP3", CodigoIdentificadorNegocio: 1, DataHoraNegocio: at(17, 0), PrecoNegocio: decimal.NewFromInt(10), QuantidadeNegociada: 100},
+		{DataNegocio: day, DataReferencia: day, CodigoInstrumento: "TWAP3", CodigoIdentificadorNegocio: 2, DataHoraNegocio: at(17, 30), PrecoNegocio: decimal.NewFromInt(20), QuantidadeNegociada: 300},
+	}
+	_, err = repo.SaveBatch(t.Context(), trades)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	sessions, err := repo.GetSessionAverages(t.Context(), "TWAP3", day, day)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, sessions, 1) {
+		t.FailNow()
+	}
+	assert.True(t, decimal.RequireFromString("15").Equal(sessions[0].TWAP), "got %s", sessions[0].TWAP)
+	assert.True(t, decimal.RequireFromString("17.5").Equal(sessions[0].VWAP), "got %s", sessions[0].VWAP)
+
+	windows, err := repo.GetWindowAverages(t.Context(), "TWAP3", day, day, time.Hour)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, windows, 1) {
+		t.FailNow()
+	}
+	assert.True(t, decimal.RequireFromString("15").Equal(windows[0].TWAP), "got %s", windows[0].TWAP)
+
+	// In 15 minute windows each trade is alone, holding until its window ends.
+	windows, err = repo.GetWindowAverages(t.Context(), "TWAP3", day, day, 15*time.Minute)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, windows, 2) {
+		t.FailNow()
+	}
+	assert.True(t, decimal.RequireFromString("10").Equal(windows[0].TWAP), "got %s", windows[0].TWAP)
+	assert.True(t, decimal.RequireFromString("20").Equal(windows[1].TWAP), "got %s", windows[1].TWAP)
+}
+
+func TestTradeRepository_Averages_EarlierCloseInNewYorkDaylightSavingTime(t *testing.T) {
+	repo := testRepository(t)
+
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// In New York daylight saving time the session closes at 17:00.
+	day := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 8, 6, hour, minute, 0, 0, saoPaulo)
+	}
+
+	// 10 holds from 16:00 to 16:30 and 20 from 16:30 to the session close. The
+	// after-market trade at 17:30 holds no time.
+	trades := []trade.Trade{
+		{DataNegocio: day, DataReferencia: day, CodigoInstrumento: "TWAP3", CodigoIdentificadorNegocio: 1, DataHoraNegocio: at(16, 0), PrecoNegocio: decimal.NewFromInt(10), QuantidadeNegociada: 100},
+		{DataNegocio: day, DataReferencia: day, CodigoInstrumento: "TWAP3", CodigoIdentificadorNegocio: 2, DataHoraNegocio: at(16, 30), PrecoNegocio: decimal.NewFromInt(20), QuantidadeNegociada: 300},
+		{DataNegocio: day, DataReferencia: day, CodigoInstrumento: "TWAP3", CodigoIdentificadorNegocio: 3, DataHoraNegocio: at(17, 30), PrecoNegocio: decimal.NewFromInt(30), QuantidadeNegociada: 100},
+	}
+	_, err = repo.SaveBatch(t.Context(), trades)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	sessions, err := repo.GetSessionAverages(t.Context(), "TWAP3", day, day)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, sessions, 1) {
+		t.FailNow()
+	}
+	assert.True(t, decimal.RequireFromString("15").Equal(sessions[0].TWAP), "got %s", sessions[0].TWAP)
+	assert.True(t, decimal.RequireFromString("20").Equal(sessions[0].VWAP), "got %s", sessions[0].VWAP)
+
+	windows, err := repo.GetWindowAverages(t.Context(), "TWAP3", day, day, time.Hour)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, windows, 2) {
+		t.FailNow()
+	}
+	assert.True(t, decimal.RequireFromString("15").Equal(windows[0].TWAP), "got %s", windows[0].TWAP)
+	// The window after the close only has the after-market trade, which holds no time.
+	assert.True(t, decimal.RequireFromString("30").Equal(windows[1].TWAP), "got %s", windows[1].TWAP)
+}
+
+func TestTradeRepository_SaveBatch_ReusesTheStagingTable(t *testing.T) {
+	repo := testRepository(t)
+
+	day := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+	newTrade := func(id int64) trade.Trade {
+		return trade.Trade{DataNegocio: day, DataReferencia: day, CodigoInstrumento: "STAG3", CodigoIdentificadorNegocio: id,
+			DataHoraNegocio: day.Add(13 * time.Hour), PrecoNegocio: decimal.NewFromInt(10), QuantidadeNegociada: 100}
+	}
+
+	// Every batch of a file goes through the same staging tables, emptied in between.
+	inserted, err := repo.SaveBatch(t.Context(), []trade.Trade{newTrade(1), newTrade(2)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), inserted)
+
+	inserted, err = repo.SaveBatch(t.Context(), []trade.Trade{newTrade(2), newTrade(3)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), inserted)
+
+	cancelled, err := repo.CancelTrades(t.Context(), []trade.Trade{newTrade(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), cancelled)
+
+	cancelled, err = repo.CancelTrades(t.Context(), []trade.Trade{newTrade(3)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), cancelled)
+
+	var count, cancelledCount int
+	err = repo.db.QueryRow(t.Context(),
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE cancelado) FROM trades WHERE codigo_instrumento = 'STAG3'`,
+	).Scan(&count, &cancelledCount)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 2, cancelledCount)
+}
+
+func TestTradeRepository_SaveBatch_WaitsForFilesOfTheSameSession(t *testing.T) {
+	pool := testPool(t)
+	first, second := beginRepository(t, pool), beginRepository(t, pool)
+
+	day := time.Date(2025, 8, 7, 0, 0, 0, 0, time.UTC)
+	trades := []trade.Trade{{DataNegocio: day, DataReferencia: day, CodigoInstrumento: "LOCK3", CodigoIdentificadorNegocio: 1,
+		DataHoraNegocio: day.Add(13 * time.Hour), PrecoNegocio: decimal.NewFromInt(10), QuantidadeNegociada: 100}}
+
+	_, err := first.SaveBatch(t.Context(), trades)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	type result struct {
+		inserted int64
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		inserted, err := second.SaveBatch(t.Context(), trades)
+		done <- result{inserted, err}
+	}()
+
+	// The second file waits for the session until the first one ends.
+	select {
+	case <-done:
+		t.Fatal("second file wrote the session while the first was open")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if !assert.NoError(t, first.db.(pgx.Tx).Rollback(t.Context())) {
+		t.FailNow()
+	}
+	got := <-done
+	assert.NoError(t, got.err)
+	assert.Equal(t, int64(1), got.inserted)
+}
+
+func TestTradeRepository_WithinTransaction_DeadlockIsAConflict(t *testing.T) {
+	repo := NewTradeRepository(testPool(t))
+
+	day := func(d int) time.Time { return time.Date(2025, 8, d, 0, 0, 0, 0, time.UTC) }
+	newTrade := func(date time.Time) trade.Trade {
+		return trade.Trade{DataNegocio: date, DataReferencia: date, CodigoInstrumento: "LOCK3", CodigoIdentificadorNegocio: 1,
+			DataHoraNegocio: date.Add(13 * time.Hour), PrecoNegocio: decimal.NewFromInt(10), QuantidadeNegociada: 100}
+	}
+
+	// Each file writes its first session and then the session of the other
+	// one. Both are rolled back, so nothing is left in the database.
+	errRollback := errors.New("rollback")
+	var ready sync.WaitGroup
+	ready.Add(2)
+	write := func(first, second time.Time) error {
+		return repo.WithinTransaction(t.Context(), func(ctx context.Context, w trade.Writer) error {
+			if _, err := w.SaveBatch(ctx, []trade.Trade{newTrade(first)}); err != nil {
+				ready.Done()
+				return err
+			}
+			ready.Done()
+			ready.Wait()
+			if _, err := w.SaveBatch(ctx, []trade.Trade{newTrade(second)}); err != nil {
+				return err
+			}
+			return errRollback
+		})
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- write(day(7), day(8)) }()
+	go func() { errs <- write(day(8), day(7)) }()
+
+	// Postgres aborts one of the files, which can be retried, and the other goes on.
+	results := []error{<-errs, <-errs}
+	assert.ElementsMatch(t, []bool{true, false}, []bool{errors.Is(results[0], trade.ErrConflict), errors.Is(results[1], trade.ErrConflict)})
+	assert.ElementsMatch(t, []bool{true, false}, []bool{errors.Is(results[0], errRollback), errors.Is(results[1], errRollback)})
+}
+
+func TestTradeRepository_ReplaceBatch_UpdatesChangedTrades(t *testing.T) {
+	repo := testRepository(t)
+
+	day := time.Date(2025, 8, 8, 0, 0, 0, 0, time.UTC)
+	newTrade := func(id int64, price int64) trade.Trade {
+		return trade.Trade{DataNegocio: day, DataReferencia: day, CodigoInstrumento: "REPL3", CodigoIdentificadorNegocio: id,
+			DataHoraNegocio: day.Add(13 * time.Hour), PrecoNegocio: decimal.NewFromInt(price), QuantidadeNegociada: 100}
+	}
+
+	_, err := repo.SaveBatch(t.Context(), []trade.Trade{newTrade(1, 10), newTrade(2, 10), newTrade(3, 10)})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	corrected := newTrade(3, 15)
+	corrected.AcaoAtualizacao = 1
+	_, err = repo.CorrectTrades(t.Context(), []trade.Trade{corrected})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// The file is loaded again with the first trade changed, repeated, and a new one.
+	written, err := repo.ReplaceBatch(t.Context(), []trade.Trade{newTrade(1, 11), newTrade(1, 12), newTrade(2, 10), newTrade(3, 10), newTrade(4, 10)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), written)
+
+	rows, err := repo.db.Query(t.Context(),
+		`SELECT codigo_identificador_negocio, preco_negocio::text FROM trades WHERE codigo_instrumento = 'REPL3' ORDER BY 1`)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	prices := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var price string
+		if assert.NoError(t, rows.Scan(&id, &price)) {
+			prices[id] = price
+		}
+	}
+	assert.NoError(t, rows.Err())
+
+	// The correction of the third trade is kept.
+	for id, want := range map[int64]int64{1: 12, 2: 10, 3: 15, 4: 10} {
+		got, err := decimal.NewFromString(prices[id])
+		if assert.NoError(t, err, "trade %d", id) {
+			assert.True(t, decimal.NewFromInt(want).Equal(got), "trade %d: got %s", id, got)
+		}
+	}
+}
+
+func TestTradeRepository_GetAggregatedData_IncludesTheStandardLot(t *testing.T) {
+	repo := testRepository(t)
+
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	first, second := time.Date(2025, 8, 4, 0, 0, 0, 0, time.UTC), time.Date(2025, 8, 5, 0, 0, 0, 0, time.UTC)
+	newTrade := func(code string, id int64, day time.Time, hour, price, quantity int) trade.Trade {
+		return trade.Trade{DataNegocio: day, DataReferencia: day, CodigoInstrumento: code, CodigoIdentificadorNegocio: id,
+			DataHoraNegocio: time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, saoPaulo),
+			PrecoNegocio:    decimal.NewFromInt(int64(price)), QuantidadeNegociada: quantity}
+	}
+
+	cancelled := newTrade("AGGR4", 3, second, 17, 20, 1000)
+	_, err = repo.SaveBatch(t.Context(), []trade.Trade{
+		newTrade("AGGR4F", 1, first, 10, 10, 50),
+		newTrade("AGGR4F", 2, second, 11, 12, 30),
+		newTrade("AGGR4", 1, first, 11, 11, 100),
+		newTrade("AGGR4", 2, second, 16, 9, 200),
+		cancelled,
+		newTrade("AGGR3", 1, first, 10, 50, 100),
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = repo.CancelTrades(t.Context(), []trade.Trade{cancelled})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// The fractional ticker and its standard lot are aggregated as one ticker.
+	data, err := repo.GetAggregatedData(t.Context(), "AGGR4F", []string{"AGGR4F", "AGGR4"}, first, second)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "AGGR4F", data.Ticker)
+	assert.Equal(t, int64(4), data.TradeCount)
+	assert.Equal(t, int64(380), data.TotalVolume)
+	assert.True(t, decimal.NewFromInt(12).Equal(data.MaxRangeValue), "got %s", data.MaxRangeValue)
+	assert.True(t, decimal.NewFromInt(9).Equal(data.MinRangeValue), "got %s", data.MinRangeValue)
+	assert.True(t, decimal.NewFromInt(10).Equal(data.OpenPrice), "got %s", data.OpenPrice)
+	assert.True(t, decimal.NewFromInt(9).Equal(data.ClosePrice), "got %s", data.ClosePrice)
+	assert.Equal(t, 230, data.MaxDailyVolume)
+	assert.True(t, second.Equal(data.MaxDailyVolumeDate), "got %s", data.MaxDailyVolumeDate)
+
+	// Without the standard lot only the fractional trades are aggregated.
+	data, err = repo.GetAggregatedData(t.Context(), "AGGR4F", []string{"AGGR4F"}, first, second)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, int64(2), data.TradeCount)
+	assert.Equal(t, int64(80), data.TotalVolume)
+
+	_, err = repo.GetAggregatedData(t.Context(), "NONE3", []string{"NONE3"}, first, second)
+	assert.ErrorIs(t, err, trade.ErrNotFound)
+}
+
+func TestTradeRepository_GetAggregatedDataBatch(t *testing.T) {
+	repo := testRepository(t)
+
+	day := time.Date(2025, 8, 4, 0, 0, 0, 0, time.UTC)
+	newTrade := func(code string, id int64, hour, price int) trade.Trade {
+		return trade.Trade{DataNegocio: day, DataReferencia: day, CodigoInstrumento: code, CodigoIdentificadorNegocio: id,
+			DataHoraNegocio: day.Add(time.Duration(hour) * time.Hour), PrecoNegocio: decimal.NewFromInt(int64(price)), QuantidadeNegociada: 100}
+	}
+
+	_, err := repo.SaveBatch(t.Context(), []trade.Trade{
+		newTrade("BTCH4", 1, 13, 10),
+		newTrade("BTCH4", 2, 14, 11),
+		newTrade("BTCH3", 1, 13, 20),
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// Tickers without trades are left out, and the others come in ticker order.
+	data, err := repo.GetAggregatedDataBatch(t.Context(), []string{"BTCH4", "NONE3", "BTCH3"}, day, day)
+	if !assert.NoError(t, err) || !assert.Len(t, data, 2) {
+		t.FailNow()
+	}
+	assert.Equal(t, "BTCH3", data[0].Ticker)
+	assert.Equal(t, int64(1), data[0].TradeCount)
+	assert.Equal(t, "BTCH4", data[1].Ticker)
+	assert.Equal(t, int64(2), data[1].TradeCount)
+	assert.True(t, decimal.NewFromInt(10).Equal(data[1].OpenPrice), "got %s", data[1].OpenPrice)
+	assert.True(t, decimal.NewFromInt(11).Equal(data[1].ClosePrice), "got %s", data[1].ClosePrice)
+}
+
+func TestTradeRepository_GetDailyStats(t *testing.T) {
+	repo := testRepository(t)
+
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	first, cancelledDay, last := time.Date(2025, 8, 4, 0, 0, 0, 0, time.UTC), time.Date(2025, 8, 5, 0, 0, 0, 0, time.UTC), time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+	newTrade := func(id int64, day time.Time, hour, price int) trade.Trade {
+		return trade.Trade{DataNegocio: day, DataReferencia: day, CodigoInstrumento: "DAYS3", CodigoIdentificadorNegocio: id,
+			DataHoraNegocio: time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, saoPaulo),
+			PrecoNegocio:    decimal.NewFromInt(int64(price)), QuantidadeNegociada: 100}
+	}
+
+	// The first two trades have the same time, so the trade id orders them.
+	cancelled := newTrade(1, cancelledDay, 10, 50)
+	_, err = repo.SaveBatch(t.Context(), []trade.Trade{
+		newTrade(1, first, 10, 12),
+		newTrade(2, first, 10, 10),
+		newTrade(3, first, 11, 13),
+		cancelled,
+		newTrade(1, last, 10, 20),
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = repo.CancelTrades(t.Context(), []trade.Trade{cancelled})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	days, err := repo.GetDailyStats(t.Context(), "DAYS3", first, last)
+	if !assert.NoError(t, err) || !assert.Len(t, days, 2) {
+		t.FailNow()
+	}
+
+	// A session with only cancelled trades is left out.
+	assert.True(t, first.Equal(days[0].Date), "got %s", days[0].Date)
+	assert.True(t, decimal.NewFromInt(12).Equal(days[0].Open), "got %s", days[0].Open)
+	assert.True(t, decimal.NewFromInt(13).Equal(days[0].High), "got %s", days[0].High)
+	assert.True(t, decimal.NewFromInt(10).Equal(days[0].Low), "got %s", days[0].Low)
+	assert.True(t, decimal.NewFromInt(13).Equal(days[0].Close), "got %s", days[0].Close)
+	assert.True(t, decimal.RequireFromString("11.66666667").Equal(days[0].VWAP), "got %s", days[0].VWAP)
+	assert.Equal(t, int64(300), days[0].Volume)
+	assert.Equal(t, int64(3), days[0].TradeCount)
+
+	assert.True(t, last.Equal(days[1].Date), "got %s", days[1].Date)
+	assert.True(t, decimal.NewFromInt(20).Equal(days[1].VWAP), "got %s", days[1].VWAP)
+	assert.Equal(t, int64(1), days[1].TradeCount)
+}
+
+func TestTradeRepository_GetCandles(t *testing.T) {
+	repo := testRepository(t)
+
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	day := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 8, 6, hour, minute, 0, 0, saoPaulo)
+	}
+	newTrade := func(id int64, tradedAt time.Time, price, quantity int) trade.Trade {
+		return trade.Trade{DataNegocio: day, DataReferencia: day, CodigoInstrumento: "CNDL3", CodigoIdentificadorNegocio: id,
+			DataHoraNegocio: tradedAt, PrecoNegocio: decimal.NewFromInt(int64(price)), QuantidadeNegociada: quantity}
+	}
+
+	cancelled := newTrade(5, at(10, 12), 50, 100)
+	_, err = repo.SaveBatch(t.Context(), []trade.Trade{
+		newTrade(1, at(10, 1), 10, 100),
+		newTrade(2, at(10, 3), 12, 200),
+		newTrade(3, at(10, 4), 11, 300),
+		newTrade(4, at(10, 7), 9, 400),
+		cancelled,
+		// A trade of another session is left out.
+		{DataNegocio: day.AddDate(0, 0, 1), DataReferencia: day.AddDate(0, 0, 1), CodigoInstrumento: "CNDL3", CodigoIdentificadorNegocio: 1,
+			DataHoraNegocio: at(10, 1).AddDate(0, 0, 1), PrecoNegocio: decimal.NewFromInt(99), QuantidadeNegociada: 100},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = repo.CancelTrades(t.Context(), []trade.Trade{cancelled})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// Five-minute candles start at 10:00 and 10:05 São Paulo time, and the
+	// interval with only a cancelled trade is left out.
+	candles, err := repo.GetCandles(t.Context(), "CNDL3", day, 5*time.Minute)
+	if !assert.NoError(t, err) || !assert.Len(t, candles, 2) {
+		t.FailNow()
+	}
+
+	assert.True(t, at(10, 0).Equal(candles[0].Time), "got %s", candles[0].Time)
+	assert.True(t, decimal.NewFromInt(10).Equal(candles[0].Open), "got %s", candles[0].Open)
+	assert.True(t, decimal.NewFromInt(12).Equal(candles[0].High), "got %s", candles[0].High)
+	assert.True(t, decimal.NewFromInt(10).Equal(candles[0].Low), "got %s", candles[0].Low)
+	assert.True(t, decimal.NewFromInt(11).Equal(candles[0].Close), "got %s", candles[0].Close)
+	assert.Equal(t, int64(600), candles[0].Volume)
+	assert.Equal(t, int64(3), candles[0].TradeCount)
+
+	assert.True(t, at(10, 5).Equal(candles[1].Time), "got %s", candles[1].Time)
+	assert.True(t, decimal.NewFromInt(9).Equal(candles[1].Open), "got %s", candles[1].Open)
+	assert.Equal(t, int64(1), candles[1].TradeCount)
+}
+
+func TestTradeRepository_SaveInstruments_LastRowWins(t *testing.T) {
+	repo := testRepository(t)
+
+	day := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+	newInstrument := func(code, name string) trade.Instrument {
+		return trade.Instrument{DataReferencia: day, CodigoInstrumento: code, Ativo: "INST", Segmento: "CASH", Mercado: "EQUITY-CASH",
+			Categoria: "SHARES", ISIN: "BRINSTACNOR0", RazaoSocial: name}
+	}
+
+	written, err := repo.SaveInstruments(t.Context(), []trade.Instrument{
+		newInstrument("INST3", "FIRST"),
+		newInstrument("INST4", "OTHER"),
+		newInstrument("INST3", "LAST"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), written)
+
+	var name string
+	err = repo.db.QueryRow(t.Context(),
+		`SELECT razao_social FROM instruments WHERE data_referencia = $1 AND codigo_instrumento = 'INST3'`, day,
+	).Scan(&name)
+	assert.NoError(t, err)
+	assert.Equal(t, "LAST", name)
+}
+
+func TestTradeRepository_SaveDailyBars_LastRowWins(t *testing.T) {
+	repo := testRepository(t)
+
+	day := time.Date(2025, 8, 6, 0, 0, 0, 0, time.UTC)
+	newBar := func(close int64) trade.DailyBar {
+		price := decimal.NewFromInt(close)
+		return trade.DailyBar{DataPregao: day, CodigoBDI: "02", CodigoInstrumento: "BARS3", TipoMercado: 10,
+			PrecoAbertura: price, PrecoMaximo: price, PrecoMinimo: price, PrecoMedio: price, PrecoFechamento: price,
+			NumeroNegocios: 1, QuantidadeTotal: 100, VolumeTotal: price.Mul(decimal.NewFromInt(100)), FatorCotacao: 1, ISIN: "BRBARSACNOR0"}
+	}
+
+	written, err := repo.SaveDailyBars(t.Context(), []trade.DailyBar{newBar(10), newBar(11)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), written)
+
+	var closing string
+	err = repo.db.QueryRow(t.Context(),
+		`SELECT preco_fechamento::text FROM daily_bars WHERE data_pregao = $1 AND codigo_instrumento = 'BARS3'`, day,
+	).Scan(&closing)
+	assert.NoError(t, err)
+	assert.Equal(t, "11.00", closing)
+}
@@ -2,63 +2,216 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gurodrigues-dev/b3-reader/trade"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var tradeColumns = []string{
+	"data_referencia",
+	"data_negocio",
+	"codigo_instrumento",
+	"acao_atualizacao",
+	"preco_negocio",
+	"quantidade_negociada",
+	"data_hora_negocio",
+	"codigo_identificador_negocio",
+	"tipo_sessao_pregao",
+	"codigo_participante_comprador",
+	"codigo_participante_vendedor",
+	"created_at",
+}
+
+// deadlockDetected is the SQLSTATE of a transaction aborted to break a deadlock.
+const deadlockDetected = "40P01"
+
+// dbtx is implemented by both *pgxpool.Pool and pgx.Tx, so the repository
+// runs the same queries inside and outside a transaction.
+type dbtx interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
 type TradeRepository struct {
-	pool *pgxpool.Pool
+	db dbtx
+	// staged holds the staging tables created in the transaction of a file,
+	// which are reused by every batch of the file. It is nil outside one.
+	staged map[string]bool
+	// locked holds the trading dates locked by the transaction of a file.
+	locked map[time.Time]bool
 }
 
 func NewTradeRepository(pool *pgxpool.Pool) *TradeRepository {
 	return &TradeRepository{
-		pool: pool,
+		db: pool,
+	}
+}
+
+func (r *TradeRepository) WithinTransaction(ctx context.Context, fn func(ctx context.Context, w trade.Writer) error) error {
+	return r.transaction(ctx, func(tx *TradeRepository) error {
+		return fn(ctx, tx)
+	})
+}
+
+// transaction runs fn with a repository bound to a new transaction, committed
+// when fn succeeds.
+func (r *TradeRepository) transaction(ctx context.Context, fn func(tx *TradeRepository) error) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction error: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := fn(&TradeRepository{db: tx, staged: make(map[string]bool), locked: make(map[time.Time]bool)}); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == deadlockDetected {
+			return fmt.Errorf("%w: %w", trade.ErrConflict, err)
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction error: %w", err)
 	}
+
+	return nil
 }
 
+// SaveBatch copies the trades into a temporary staging table and moves them to
+// trades, skipping rows whose natural key is already stored. Within
+// WithinTransaction the batch is only committed along with the outer transaction.
 func (r *TradeRepository) SaveBatch(ctx context.Context, trades []trade.Trade) (int64, error) {
 	if len(trades) == 0 {
 		return 0, nil
 	}
 
-	tableName := "trades"
-	columns := []string{
-		"data_negocio",
-		"codigo_instrumento",
-		"preco_negocio",
-		"quantidade_negociada",
-		"hora_fechamento",
-		"created_at",
-	}
-
 	rows := make([][]interface{}, len(trades))
 	for i, t := range trades {
-		if t.CreatedAt.IsZero() {
-			t.CreatedAt = time.Now()
-		}
-		rows[i] = []interface{}{
-			t.DataNegocio,
-			t.CodigoInstrumento,
-			t.PrecoNegocio,
-			t.QuantidadeNegociada,
-			t.HoraFechamento,
-			t.CreatedAt,
+		rows[i] = tradeRow(t)
+	}
+	if err := r.lockSessions(ctx, trades); err != nil {
+		return 0, err
+	}
+
+	return r.upsertRows(ctx, "trades", "trades_staging", tradeColumns, rows, "", "ON CONFLICT (data_negocio, codigo_instrumento, codigo_identificador_negocio) DO NOTHING")
+}
+
+// ReplaceBatch copies the trades into the staging table like SaveBatch, and
+// updates the stored trades that are still new trades, as a file loaded again
+// may have changed them. Trades replaced by a correction, or by a file with a
+// later DataReferencia, are kept, and unchanged trades are not rewritten.
+func (r *TradeRepository) ReplaceBatch(ctx context.Context, trades []trade.Trade) (int64, error) {
+	if len(trades) == 0 {
+		return 0, nil
+	}
+
+	rows := lastRows(trades)
+	if err := r.lockSessions(ctx, trades); err != nil {
+		return 0, err
+	}
+
+	return r.upsertRows(ctx, "trades", "trades_staging", tradeColumns, rows, "", `
+		ON CONFLICT (data_negocio, codigo_instrumento, codigo_identificador_negocio) DO UPDATE SET
+			data_referencia = EXCLUDED.data_referencia,
+			preco_negocio = EXCLUDED.preco_negocio,
+			quantidade_negociada = EXCLUDED.quantidade_negociada,
+			data_hora_negocio = EXCLUDED.data_hora_negocio,
+			tipo_sessao_pregao = EXCLUDED.tipo_sessao_pregao,
+			codigo_participante_comprador = EXCLUDED.codigo_participante_comprador,
+			codigo_participante_vendedor = EXCLUDED.codigo_participante_vendedor
+		WHERE trades.acao_atualizacao = EXCLUDED.acao_atualizacao
+			AND trades.data_referencia <= EXCLUDED.data_referencia
+			AND (trades.preco_negocio, trades.quantidade_negociada, trades.data_hora_negocio, trades.tipo_sessao_pregao,
+				trades.codigo_participante_comprador, trades.codigo_participante_vendedor)
+			IS DISTINCT FROM (EXCLUDED.preco_negocio, EXCLUDED.quantidade_negociada, EXCLUDED.data_hora_negocio, EXCLUDED.tipo_sessao_pregao,
+				EXCLUDED.codigo_participante_comprador, EXCLUDED.codigo_participante_vendedor)`,
+	)
+}
+
+// lastRows lists the rows of trades keeping only the last trade of each key,
+// since ON CONFLICT DO UPDATE cannot touch the same row twice in one statement.
+func lastRows(trades []trade.Trade) [][]interface{} {
+	latest := make(map[tradeKey]int, len(trades))
+	var rows [][]interface{}
+	for _, t := range trades {
+		row := tradeRow(t)
+		if i, ok := latest[keyOf(t)]; ok {
+			rows[i] = row
+			continue
 		}
+		latest[keyOf(t)] = len(rows)
+		rows = append(rows, row)
 	}
 
-	conn, err := r.pool.Acquire(ctx)
-	if err != nil {
-		return 0, fmt.Errorf("pool error: %w", err)
+	return rows
+}
+
+// tradeRow lists the values of a trade in the order of tradeColumns.
+func tradeRow(t trade.Trade) []interface{} {
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+
+	return []interface{}{
+		t.DataReferencia,
+		t.DataNegocio,
+		t.CodigoInstrumento,
+		t.AcaoAtualizacao,
+		numeric(t.PrecoNegocio),
+		t.QuantidadeNegociada,
+		t.DataHoraNegocio,
+		t.CodigoIdentificadorNegocio,
+		t.TipoSessaoPregao,
+		t.CodigoParticipanteComprador,
+		t.CodigoParticipanteVendedor,
+		t.CreatedAt,
+	}
+}
+
+// upsertRows copies rows into the temporary table staging, shaped like table
+// with columns, and moves them to table with INSERT ... SELECT, using
+// selectPrefix (such as DISTINCT ON) and onConflict to resolve rows already
+// stored. It returns the number of rows written.
+//
+// Within a transaction the staging table is created once, dropped on commit
+// and emptied after each batch, so a large file does not create a table or a
+// savepoint per batch. Outside one, upsertRows runs in its own transaction.
+func (r *TradeRepository) upsertRows(ctx context.Context, table, staging string, columns []string, rows [][]interface{}, selectPrefix, onConflict string) (int64, error) {
+	if r.staged == nil {
+		var count int64
+		err := r.transaction(ctx, func(tx *TradeRepository) error {
+			var err error
+			count, err = tx.upsertRows(ctx, table, staging, columns, rows, selectPrefix, onConflict)
+			return err
+		})
+		return count, err
+	}
+
+	columnList := strings.Join(columns, ", ")
+	if !r.staged[staging] {
+		createStaging := fmt.Sprintf(
+			"CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA",
+			staging, columnList, table,
+		)
+		if _, err := r.db.Exec(ctx, createStaging); err != nil {
+			return 0, fmt.Errorf("create staging table error: %w", err)
+		}
+		r.staged[staging] = true
 	}
-	defer conn.Release()
 
-	count, err := conn.Conn().CopyFrom(
+	_, err := r.db.CopyFrom(
 		ctx,
-		pgx.Identifier{tableName},
+		pgx.Identifier{staging},
 		columns,
 		pgx.CopyFromRows(rows),
 	)
@@ -66,27 +219,149 @@ func (r *TradeRepository) SaveBatch(ctx context.Context, trades []trade.Trade) (
 		return 0, fmt.Errorf("sql copy error: %w", err)
 	}
 
+	upsert := fmt.Sprintf(`
+		INSERT INTO %[1]s (%[2]s)
+		SELECT %[3]s %[2]s FROM %[4]s
+		%[5]s`,
+		table, columnList, selectPrefix, staging, onConflict,
+	)
+	tag, err := r.db.Exec(ctx, upsert)
+	if err != nil {
+		return 0, fmt.Errorf("sql upsert error: %w", err)
+	}
+
+	if _, err := r.db.Exec(ctx, "TRUNCATE "+staging); err != nil {
+		return 0, fmt.Errorf("truncate staging table error: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// CorrectTrades replaces the price, quantity and time of the trades referenced
+// by the corrections. A correction read before its trade, such as one from a
+// later file loaded by another worker, is stored as the trade itself, and
+// SaveBatch keeps it when the original arrives. Corrections older than the
+// stored trade, by DataReferencia, are ignored.
+func (r *TradeRepository) CorrectTrades(ctx context.Context, corrections []trade.Trade) (int64, error) {
+	if len(corrections) == 0 {
+		return 0, nil
+	}
+
+	rows := lastRows(corrections)
+	if err := r.lockSessions(ctx, corrections); err != nil {
+		return 0, err
+	}
+
+	return r.upsertRows(ctx, "trades", "trades_staging", tradeColumns, rows, "", `
+		ON CONFLICT (data_negocio, codigo_instrumento, codigo_identificador_negocio) DO UPDATE SET
+			data_referencia = EXCLUDED.data_referencia,
+			acao_atualizacao = EXCLUDED.acao_atualizacao,
+			preco_negocio = EXCLUDED.preco_negocio,
+			quantidade_negociada = EXCLUDED.quantidade_negociada,
+			data_hora_negocio = EXCLUDED.data_hora_negocio
+		WHERE trades.data_referencia <= EXCLUDED.data_referencia`,
+	)
+}
+
+// lockSessions takes a transaction-level advisory lock on each trading date of
+// trades not locked yet by the transaction, in date order. Files that write
+// trades of the same session are then applied one after the other: instead of
+// blocking on the rows of another open file transaction, which can deadlock
+// when both write the same keys in opposite orders, a file waits at its first
+// batch of the session until the other file commits. Outside a transaction
+// the rows are only locked by a single statement, so no lock is taken.
+func (r *TradeRepository) lockSessions(ctx context.Context, trades []trade.Trade) error {
+	if r.locked == nil {
+		return nil
+	}
+
+	var days []time.Time
+	for _, t := range trades {
+		if !r.locked[t.DataNegocio] && !slices.ContainsFunc(days, t.DataNegocio.Equal) {
+			days = append(days, t.DataNegocio)
+		}
+	}
+	slices.SortFunc(days, time.Time.Compare)
+
+	for _, day := range days {
+		key := day.Year()*10000 + int(day.Month())*100 + day.Day()
+		if _, err := r.db.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('trades'), $1)", key); err != nil {
+			return fmt.Errorf("lock trading date error %s: %w", day.Format(time.DateOnly), err)
+		}
+		r.locked[day] = true
+	}
+
+	return nil
+}
+
+// tradeKey is the natural key of a trade.
+type tradeKey struct {
+	dataNegocio                time.Time
+	codigoInstrumento          string
+	codigoIdentificadorNegocio int64
+}
+
+func keyOf(t trade.Trade) tradeKey {
+	return tradeKey{t.DataNegocio, t.CodigoInstrumento, t.CodigoIdentificadorNegocio}
+}
+
+// CancelTrades marks the trades referenced by the cancellations as cancelled.
+// A cancellation read before its trade, such as one from a later file loaded
+// by another worker, is stored as a cancelled trade, and SaveBatch keeps the
+// flag when the original arrives.
+func (r *TradeRepository) CancelTrades(ctx context.Context, cancellations []trade.Trade) (int64, error) {
+	if len(cancellations) == 0 {
+		return 0, nil
+	}
+
+	rows := make([][]interface{}, len(cancellations))
+	for i, c := range cancellations {
+		rows[i] = append(tradeRow(c), true)
+	}
+	if err := r.lockSessions(ctx, cancellations); err != nil {
+		return 0, err
+	}
+
+	columns := append(tradeColumns[:len(tradeColumns):len(tradeColumns)], "cancelado")
+	count, err := r.upsertRows(ctx, "trades", "trades_cancel_staging", columns, rows,
+		"DISTINCT ON (data_negocio, codigo_instrumento, codigo_identificador_negocio)",
+		"ON CONFLICT (data_negocio, codigo_instrumento, codigo_identificador_negocio) DO UPDATE SET cancelado = TRUE",
+	)
+	if err != nil {
+		return 0, fmt.Errorf("sql cancel trades error: %w", err)
+	}
+
 	return count, nil
 }
 
-func (r *TradeRepository) GetAggregatedData(ctx context.Context, ticker string, startDate time.Time) (float64, int, error) {
-	query := `
-		SELECT
-			MAX(preco_negocio) AS max_range_value,
-			MAX(SUM(quantidade_negociada)) OVER() AS max_daily_volume
-		FROM trades
-		WHERE codigo_instrumento = $1
-			AND data_negocio >= $2
-		GROUP BY data_negocio;
-	`
+func (r *TradeRepository) SaveRejected(ctx context.Context, rejected []trade.RejectedTrade) (int64, error) {
+	if len(rejected) == 0 {
+		return 0, nil
+	}
 
-	var maxRangeValue float64
-	var maxDailyVolume int
+	rows := make([][]interface{}, len(rejected))
+	for i, rt := range rejected {
+		if rt.CreatedAt.IsZero() {
+			rt.CreatedAt = time.Now()
+		}
+		rows[i] = []interface{}{
+			rt.FileName,
+			rt.LineNumber,
+			rt.RawLine,
+			rt.Reason,
+			rt.CreatedAt,
+		}
+	}
 
-	err := r.pool.QueryRow(ctx, query, ticker, startDate).Scan(&maxRangeValue, &maxDailyVolume)
+	count, err := r.db.CopyFrom(
+		ctx,
+		pgx.Identifier{"rejected_trades"},
+		[]string{"file_name", "line_number", "raw_line", "reason", "created_at"},
+		pgx.CopyFromRows(rows),
+	)
 	if err != nil {
-		return 0, 0, fmt.Errorf("error querying aggregated data: %w", err)
+		return 0, fmt.Errorf("sql copy rejected trades error: %w", err)
 	}
 
-	return maxRangeValue, maxDailyVolume, nil
+	return count, nil
 }
@@ -0,0 +1,62 @@
+package trade
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// fractionalSuffix marks the fractional market code of a share, e.g. PETR4F for PETR4.
+const fractionalSuffix = "F"
+
+var (
+	// tickerPattern is the structure shared by B3 trading codes: a four character
+	// root starting with a letter (PETR, B3SA, or a future root and its month,
+	// like WINV and DI1F) followed by a suffix with at least one digit.
+	tickerPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}[A-Z0-9]*[0-9][A-Z0-9]*$`)
+	// fractionalPattern matches fractional market codes of shares, units, ETFs,
+	// FIIs and BDRs.
+	fractionalPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}[0-9]{1,2}F$`)
+)
+
+const maxTickerLength = 16
+
+// ErrTickerRequired is returned when no ticker is given.
+var ErrTickerRequired = errors.New("ticker is required")
+
+// Ticker is a normalized B3 trading code.
+type Ticker struct {
+	// Code is the trading code, upper-cased and trimmed.
+	Code string
+	// Fractional reports whether Code is a fractional market code.
+	Fractional bool
+}
+
+// ParseTicker normalizes and validates a trading code as typed by users or
+// published in B3 files. Invalid codes are reported as ErrValidation.
+func ParseTicker(raw string) (Ticker, error) {
+	code := strings.ToUpper(strings.TrimSpace(raw))
+	if code == "" {
+		return Ticker{}, validationError(ErrTickerRequired)
+	}
+
+	if len(code) > maxTickerLength || !tickerPattern.MatchString(code) {
+		return Ticker{}, validationError(fmt.Errorf("invalid ticker %q", raw))
+	}
+
+	return Ticker{Code: code, Fractional: fractionalPattern.MatchString(code)}, nil
+}
+
+// Lot returns the standard lot code of the ticker, mapping fractional market
+// codes to the code they are a fraction of.
+func (t Ticker) Lot() string {
+	if t.Fractional {
+		return strings.TrimSuffix(t.Code, fractionalSuffix)
+	}
+	return t.Code
+}
+
+func (t Ticker) String() string {
+	return t.Code
+}
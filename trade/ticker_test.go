@@ -0,0 +1,48 @@
+package trade
+
+import "testing"
+
+func TestParseTicker(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    Ticker
+		lot     string
+		wantErr bool
+	}{
+		{name: "share", raw: "PETR4", want: Ticker{Code: "PETR4"}, lot: "PETR4"},
+		{name: "lower case and spaces", raw: "  vale3 ", want: Ticker{Code: "VALE3"}, lot: "VALE3"},
+		{name: "unit", raw: "TAEE11", want: Ticker{Code: "TAEE11"}, lot: "TAEE11"},
+		{name: "digit in root", raw: "B3SA3", want: Ticker{Code: "B3SA3"}, lot: "B3SA3"},
+		{name: "fractional", raw: "petr4f", want: Ticker{Code: "PETR4F", Fractional: true}, lot: "PETR4"},
+		{name: "fractional unit", raw: "BOVA11F", want: Ticker{Code: "BOVA11F", Fractional: true}, lot: "BOVA11"},
+		{name: "option", raw: "PETRH300", want: Ticker{Code: "PETRH300"}, lot: "PETRH300"},
+		{name: "weekly option", raw: "PETRI325W2", want: Ticker{Code: "PETRI325W2"}, lot: "PETRI325W2"},
+		{name: "future", raw: "WINV24", want: Ticker{Code: "WINV24"}, lot: "WINV24"},
+		{name: "empty", raw: "   ", wantErr: true},
+		{name: "root only", raw: "PETR", wantErr: true},
+		{name: "short root", raw: "AB4", wantErr: true},
+		{name: "starts with digit", raw: "3PET4", wantErr: true},
+		{name: "symbol", raw: "PETR-4", wantErr: true},
+		{name: "inner space", raw: "PETR 4", wantErr: true},
+		{name: "too long", raw: "PETR4444444444444", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTicker(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTicker(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseTicker(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+			if lot := got.Lot(); lot != tt.lot {
+				t.Errorf("Lot() = %q, want %q", lot, tt.lot)
+			}
+		})
+	}
+}
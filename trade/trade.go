@@ -1,44 +1,366 @@
+// Package trade ingests B3 trade files and serves aggregations over them.
+//
+// The read models share one convention: prices are decimals encoded as JSON
+// strings, so they stay exact, and cancelled trades are left out.
 package trade
 
 import (
 	"context"
+	"fmt"
 	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Values of the AcaoAtualizacao column published by B3.
+const (
+	// AcaoAtualizacaoNovo marks a regular trade.
+	AcaoAtualizacaoNovo = 0
+	// AcaoAtualizacaoCorrecao marks the correction of a previously published
+	// trade, carrying its new price, quantity and time.
+	AcaoAtualizacaoCorrecao = 1
+	// AcaoAtualizacaoCancelamento marks the cancellation of a previously published trade.
+	AcaoAtualizacaoCancelamento = 2
 )
 
 type Trade struct {
-	ID                  uint
-	CodigoInstrumento   string
-	HoraFechamento      string
-	QuantidadeNegociada int
-	PrecoNegocio        float64
-	DataNegocio         time.Time
-	CreatedAt           time.Time
+	ID                uint
+	DataReferencia    time.Time
+	CodigoInstrumento string
+	AcaoAtualizacao   int
+	// DataHoraNegocio is the time of the trade, with milliseconds, in America/Sao_Paulo.
+	DataHoraNegocio             time.Time
+	QuantidadeNegociada         int
+	PrecoNegocio                decimal.Decimal
+	CodigoIdentificadorNegocio  int64
+	TipoSessaoPregao            int
+	DataNegocio                 time.Time
+	CodigoParticipanteComprador int
+	CodigoParticipanteVendedor  int
+	CreatedAt                   time.Time
+}
+
+// IsCorrection reports whether the record corrects the trade identified by
+// DataNegocio, CodigoInstrumento and CodigoIdentificadorNegocio.
+func (t Trade) IsCorrection() bool {
+	return t.AcaoAtualizacao == AcaoAtualizacaoCorrecao
+}
+
+// IsCancellation reports whether the record cancels the trade identified by
+// DataNegocio, CodigoInstrumento and CodigoIdentificadorNegocio.
+func (t Trade) IsCancellation() bool {
+	return t.AcaoAtualizacao == AcaoAtualizacaoCancelamento
+}
+
+// Instrument is an entry of the B3 instrument registry (cadastro de instrumentos)
+// on a reference date.
+type Instrument struct {
+	DataReferencia    time.Time
+	CodigoInstrumento string
+	Ativo             string
+	Segmento          string
+	Mercado           string
+	Categoria         string
+	ISIN              string
+	// DataVencimento is nil for instruments that do not expire.
+	DataVencimento *time.Time
+	RazaoSocial    string
+}
+
+// DailyBar is the daily quote of an instrument as published by B3 in the COTAHIST
+// historical series. Prices refer to FatorCotacao units of the instrument.
+type DailyBar struct {
+	DataPregao        time.Time
+	CodigoBDI         string
+	CodigoInstrumento string
+	TipoMercado       int
+	PrecoAbertura     decimal.Decimal
+	PrecoMaximo       decimal.Decimal
+	PrecoMinimo       decimal.Decimal
+	PrecoMedio        decimal.Decimal
+	PrecoFechamento   decimal.Decimal
+	NumeroNegocios    int
+	QuantidadeTotal   int64
+	VolumeTotal       decimal.Decimal
+	FatorCotacao      int
+	ISIN              string
 }
 
+// AggregatedData summarizes the trades of a ticker over a period.
 type AggregatedData struct {
-	Ticker         string  `json:"ticker"`
-	MaxDailyVolume int     `json:"max_daily_volume"`
-	MaxRangeValue  float64 `json:"max_range_value"`
+	Ticker string `json:"ticker"`
+	// StartDate and EndDate are the first and last days of the period, inclusive.
+	StartDate time.Time `json:"start_date" example:"2025-07-29T00:00:00Z"`
+	EndDate   time.Time `json:"end_date" example:"2025-08-06T00:00:00Z"`
+	// MaxDailyVolume is the largest quantity traded in a single day of the
+	// period, and MaxDailyVolumeDate the day it happened (the earliest on ties).
+	MaxDailyVolume     int             `json:"max_daily_volume"`
+	MaxDailyVolumeDate time.Time       `json:"max_daily_volume_date" example:"2025-08-06T00:00:00Z"`
+	MaxRangeValue      decimal.Decimal `json:"max_range_value" swaggertype:"string" example:"37.05"`
+	MinRangeValue      decimal.Decimal `json:"min_range_value" swaggertype:"string" example:"35.80"`
+	// OpenPrice and ClosePrice are the prices of the first and last trades of the period.
+	OpenPrice  decimal.Decimal `json:"open_price" swaggertype:"string" example:"36.12"`
+	ClosePrice decimal.Decimal `json:"close_price" swaggertype:"string" example:"36.90"`
+	// TotalVolume is the quantity traded in the whole period.
+	TotalVolume int64 `json:"total_volume" example:"48210300"`
+	TradeCount  int64 `json:"trade_count" example:"152340"`
+}
+
+// AggregatedBatch holds the aggregated data of several tickers over the same period.
+type AggregatedBatch struct {
+	StartDate time.Time `json:"start_date" example:"2025-07-29T00:00:00Z"`
+	EndDate   time.Time `json:"end_date" example:"2025-08-06T00:00:00Z"`
+	// Data has one entry per ticker with trades in the period, in the order requested.
+	Data []AggregatedData `json:"data"`
+	// NotFound lists the tickers without trades in the period.
+	NotFound []string `json:"not_found" example:"XPTO3"`
+}
+
+// DailyStats summarizes the trades of a ticker in one session.
+type DailyStats struct {
+	Date time.Time `json:"date" example:"2025-08-06T00:00:00Z"`
+	// Open and Close are the prices of the first and last trades of the session.
+	Open  decimal.Decimal `json:"open" swaggertype:"string" example:"36.12"`
+	High  decimal.Decimal `json:"high" swaggertype:"string" example:"37.05"`
+	Low   decimal.Decimal `json:"low" swaggertype:"string" example:"35.80"`
+	Close decimal.Decimal `json:"close" swaggertype:"string" example:"36.90"`
+	// VWAP is the volume-weighted average price, rounded to 8 decimal places.
+	VWAP       decimal.Decimal `json:"vwap" swaggertype:"string" example:"36.47215530"`
+	Volume     int64           `json:"volume" example:"9840100"`
+	TradeCount int64           `json:"trade_count" example:"30512"`
+}
+
+// DailySeries is the daily statistics of a ticker over a period, one entry per
+// session with trades, oldest first.
+type DailySeries struct {
+	Ticker    string       `json:"ticker"`
+	StartDate time.Time    `json:"start_date" example:"2025-07-29T00:00:00Z"`
+	EndDate   time.Time    `json:"end_date" example:"2025-08-06T00:00:00Z"`
+	Sessions  []DailyStats `json:"sessions"`
+}
+
+// RejectedTrade is a row that could not be parsed, kept in quarantine for inspection.
+type RejectedTrade struct {
+	FileName   string
+	LineNumber int
+	RawLine    string
+	Reason     string
+	CreatedAt  time.Time
+}
+
+// ErrorPolicy defines how the ingestion handles rows that cannot be parsed.
+type ErrorPolicy string
+
+const (
+	// ErrorPolicyFailFast aborts the file on the first invalid row.
+	ErrorPolicyFailFast ErrorPolicy = "fail-fast"
+	// ErrorPolicySkip drops invalid rows, only counting them.
+	ErrorPolicySkip ErrorPolicy = "skip"
+	// ErrorPolicyQuarantine stores invalid rows in the rejected trades table.
+	ErrorPolicyQuarantine ErrorPolicy = "quarantine"
+)
+
+// ParseErrorPolicy validates the name of an error policy.
+func ParseErrorPolicy(policy string) (ErrorPolicy, error) {
+	switch p := ErrorPolicy(policy); p {
+	case ErrorPolicyFailFast, ErrorPolicySkip, ErrorPolicyQuarantine:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid error policy %q", policy)
+	}
+}
+
+// IngestSummary reports the outcome of an ingestion run.
+type IngestSummary struct {
+	Files int
+	// Skipped is the number of files left out because they were already ingested.
+	Skipped int
+	// Rows is the number of data rows read, cancellation records included.
+	Rows       int64
+	Inserted   int64
+	Duplicated int64
+	Corrected  int64
+	Cancelled  int64
+	// Rejected is the number of rows that failed to parse under the skip or quarantine policies.
+	Rejected int64
+	// MissingSessions are the B3 sessions without stored data, per dataset,
+	// around the trading dates of the files ingested.
+	MissingSessions []MissingSession
+
+	// sessions are the trading dates read per dataset.
+	sessions map[Dataset]map[time.Time]struct{}
+}
+
+// Dataset is a kind of data stored by trading date.
+type Dataset string
+
+const (
+	// DatasetTrades are the intraday trades, by DataNegocio.
+	DatasetTrades Dataset = "trades"
+	// DatasetDailyBars are the COTAHIST daily quotes, by DataPregao.
+	DatasetDailyBars Dataset = "daily_bars"
+)
+
+// MissingSession is a B3 session without stored data of a dataset.
+type MissingSession struct {
+	Dataset Dataset
+	Date    time.Time
+}
+
+// addSession records a trading date of a dataset read from a file.
+func (s *IngestSummary) addSession(dataset Dataset, day time.Time) {
+	if s.sessions == nil {
+		s.sessions = make(map[Dataset]map[time.Time]struct{})
+	}
+	if s.sessions[dataset] == nil {
+		s.sessions[dataset] = make(map[time.Time]struct{})
+	}
+	s.sessions[dataset][day] = struct{}{}
+}
+
+func (s *IngestSummary) add(o IngestSummary) {
+	s.Files += o.Files
+	s.Skipped += o.Skipped
+	s.Rows += o.Rows
+	s.Inserted += o.Inserted
+	s.Duplicated += o.Duplicated
+	s.Corrected += o.Corrected
+	s.Cancelled += o.Cancelled
+	s.Rejected += o.Rejected
+	for dataset, days := range o.sessions {
+		for day := range days {
+			s.addSession(dataset, day)
+		}
+	}
+}
+
+// Status of a file in the ingestion ledger.
+const (
+	FileStatusProcessing = "processing"
+	FileStatusDone       = "done"
+	FileStatusFailed     = "failed"
+	// FileStatusSkipped marks a file with the content of a file ingested under
+	// another name, so it is not hashed again while it is unchanged.
+	FileStatusSkipped = "skipped"
+)
+
+// IngestedFile is the ingestion ledger entry of an input file.
+type IngestedFile struct {
+	ID       int64
+	FileName string
+	FileSize int64
+	// FileModTime is the modification time of the file on disk. It is nil for
+	// files ingested before it was recorded.
+	FileModTime    *time.Time
+	Checksum       string
+	RowsRead       int64
+	RowsInserted   int64
+	RowsDuplicated int64
+	RowsRejected   int64
+	Status         string
+	Error          string
+	StartedAt      time.Time
+	FinishedAt     *time.Time
 }
 
 type Writer interface {
-	// Insert trades in batches into the database.
+	// Insert trades in batches into the database, skipping trades already stored.
+	// It returns the number of new trades.
 	SaveBatch(ctx context.Context, trades []Trade) (int64, error)
+	// Insert trades in batches like SaveBatch, replacing the values of the
+	// trades already stored as new trades when they changed, for files loaded
+	// again. Trades stored by a correction keep it. It returns the number of
+	// trades inserted or changed.
+	ReplaceBatch(ctx context.Context, trades []Trade) (int64, error)
+	// Replace the price, quantity and time of the trades referenced by
+	// correction records, storing the corrected trade when the original is not
+	// stored yet. It returns the number of trades written.
+	CorrectTrades(ctx context.Context, corrections []Trade) (int64, error)
+	// Mark the trades referenced by cancellation records as cancelled, storing
+	// the cancelled trade when the original is not stored yet. It returns the
+	// number of trades written.
+	CancelTrades(ctx context.Context, cancellations []Trade) (int64, error)
+	// Insert or update entries of the instrument registry. It returns the number of rows written.
+	SaveInstruments(ctx context.Context, instruments []Instrument) (int64, error)
+	// Insert or update daily bars of the historical series. It returns the number of rows written.
+	SaveDailyBars(ctx context.Context, bars []DailyBar) (int64, error)
+}
+
+type Transactor interface {
+	// Run fn inside a database transaction. Writes made through w are committed when fn returns nil and rolled back otherwise.
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context, w Writer) error) error
+}
+
+type Ledger interface {
+	// Search the ledger entry of a file by name. It returns nil when the file was never ingested.
+	GetIngestedFile(ctx context.Context, fileName string) (*IngestedFile, error)
+	// Search a file ingested successfully with the given checksum, under any
+	// name. It returns nil when there is none.
+	GetIngestedFileByChecksum(ctx context.Context, checksum string) (*IngestedFile, error)
+	// Register that the ingestion of a file started, resetting any previous
+	// entry with the same name along with its quarantined rows.
+	StartIngestedFile(ctx context.Context, file *IngestedFile) error
+	// Store rows that failed to parse in the rejected trades quarantine. It is
+	// called outside the file transaction, so the rows are kept when the file fails.
+	SaveRejected(ctx context.Context, rejected []RejectedTrade) (int64, error)
+	// Record the final status and row counts of a file.
+	FinishIngestedFile(ctx context.Context, file *IngestedFile) error
+	// List the trading dates with stored data of a dataset, oldest first, from
+	// the last date stored before startDate up to endDate.
+	GetStoredSessions(ctx context.Context, dataset Dataset, startDate, endDate time.Time) ([]time.Time, error)
 }
 
 type Reader interface {
-	// Search aggregated data by date and volume of a trade.
-	GetAggregatedData(ctx context.Context, ticker string, startDate time.Time) (float64, int, error)
+	// Aggregate the trades of the instruments in codes from startDate to endDate,
+	// inclusive, as a single ticker. Returns ErrNotFound when none of them has
+	// trades in the period.
+	GetAggregatedData(ctx context.Context, ticker string, codes []string, startDate, endDate time.Time) (*AggregatedData, error)
+	// Aggregate the trades of several tickers in a single query, leaving out the
+	// tickers without trades in the period.
+	GetAggregatedDataBatch(ctx context.Context, tickers []string, startDate, endDate time.Time) ([]AggregatedData, error)
+	// Summarize the trades of a ticker per session from startDate to endDate,
+	// oldest first. Sessions without trades are left out.
+	GetDailyStats(ctx context.Context, ticker string, startDate, endDate time.Time) ([]DailyStats, error)
+	// Summarize the trades of a ticker in a session per interval, oldest first.
+	// Intervals without trades are left out.
+	GetCandles(ctx context.Context, ticker string, date time.Time, interval time.Duration) ([]Candle, error)
+	// Calculate the VWAP and TWAP of a ticker per session, oldest first.
+	GetSessionAverages(ctx context.Context, ticker string, startDate, endDate time.Time) ([]SessionAverages, error)
+	// Calculate the VWAP and TWAP of a ticker per intraday window, oldest
+	// first. Windows without trades are left out.
+	GetWindowAverages(ctx context.Context, ticker string, startDate, endDate time.Time, interval time.Duration) ([]WindowAverages, error)
 }
 
 type Repository interface {
 	Writer
 	Reader
+	Ledger
+	Transactor
 }
 
 type Usecase interface {
-	// Ingest data into the database based on a csv folder or a single csv file.
-	IngestFiles(ctx context.Context, filePath string) error
-	// Search for volume and aggregation of a trade, using filters.
-	GetAggregatedData(ctx context.Context, ticker string, startDate *time.Time) (*AggregatedData, error)
+	// Ingest data into the database based on a folder of B3 files or a single file, loading each one
+	// according to its layout and skipping files already ingested. Invalid files fail
+	// with ErrValidation and storage failures with ErrUnavailable.
+	IngestFiles(ctx context.Context, filePath string) (*IngestSummary, error)
+	// Search for volume and aggregation of a trade, using filters. Missing dates
+	// default to the last business days. Errors are classified as ErrValidation,
+	// ErrNotFound (a *NotFoundError) or ErrUnavailable. With includeLot, the
+	// trades of the standard lot are aggregated along with those of a fractional
+	// market ticker.
+	GetAggregatedData(ctx context.Context, ticker string, startDate, endDate *time.Time, includeLot bool) (*AggregatedData, error)
+	// Search for the aggregated data of several tickers over the same period, with
+	// the same defaults and error kinds as GetAggregatedData. Tickers without
+	// trades are listed as not found instead of failing the request.
+	GetAggregatedDataBatch(ctx context.Context, tickers []string, startDate, endDate *time.Time) (*AggregatedBatch, error)
+	// Search for the open, high, low, close, VWAP, volume and trade count of each
+	// session of a ticker, with the same defaults and error kinds as GetAggregatedData.
+	GetDailyStats(ctx context.Context, ticker string, startDate, endDate *time.Time) (*DailySeries, error)
+	// Search for the intraday candles of a ticker in a session, by default the
+	// last one, with the same error kinds as GetAggregatedData.
+	GetCandles(ctx context.Context, ticker string, date *time.Time, interval CandleInterval) (*CandleSeries, error)
+	// Search for the VWAP and TWAP of a ticker per session in a period, and per
+	// intraday window when an interval is given, with the same period defaults
+	// and error kinds as GetAggregatedData.
+	GetAveragePrices(ctx context.Context, ticker string, startDate, endDate *time.Time, interval CandleInterval) (*AveragePrices, error)
 }